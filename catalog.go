@@ -0,0 +1,38 @@
+package llsr
+
+import (
+	"database/sql"
+)
+
+// Catalog holds database metadata available to converters implementing CatalogConverter.
+type Catalog struct {
+	ValuesMap ValuesMap
+	Types     TypeCatalog
+	Relations *RelationCache
+}
+
+func loadCatalog(db *sql.DB) (*Catalog, error) {
+	types, err := loadTypeCatalog(db)
+	if err != nil {
+		return nil, err
+	}
+
+	catalog := newCatalog(db)
+	catalog.Types = types
+	catalog.ValuesMap.merge(types)
+	return catalog, nil
+}
+
+// newCatalog returns Catalog without any database specific types.
+func newCatalog(db *sql.DB) *Catalog {
+	return &Catalog{ValuesMap: make(ValuesMap), Types: make(TypeCatalog), Relations: newRelationCache(db)}
+}
+
+// reload refreshes type definitions in place and returns sorted list of newly discovered OIDs.
+// Cached relations are kept, so Client still notices schema changes by comparing messages with them.
+func (c *Catalog) reload(db *sql.DB) ([]int, error) {
+	if err := c.Types.load(db); err != nil {
+		return nil, err
+	}
+	return c.ValuesMap.merge(c.Types), nil
+}
@@ -0,0 +1,38 @@
+package llsr
+
+import (
+	"database/sql"
+	"testing"
+)
+
+func TestCatalogReload(t *testing.T) {
+	withValueMapOid(t, func(t *testing.T, db *sql.DB, oid int) {
+		catalog := &Catalog{ValuesMap: make(ValuesMap), Types: make(TypeCatalog)}
+
+		discovered, err := catalog.reload(db)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !catalog.ValuesMap[oid] || catalog.Types[oid] == nil {
+			t.Fatal("Expected Catalog.reload() to add enum oid")
+		}
+
+		found := false
+		for _, discoveredOid := range discovered {
+			found = found || discoveredOid == oid
+		}
+		if !found {
+			t.Fatalf("Expected Catalog.reload() to report enum oid %d as discovered. Got: %v", oid, discovered)
+		}
+
+		discovered, err = catalog.reload(db)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(discovered) != 0 {
+			t.Fatalf("Expected second Catalog.reload() not to discover anything. Got: %v", discovered)
+		}
+	})
+}
@@ -6,25 +6,58 @@ package llsr
 import (
 	"bytes"
 	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
 	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/lib/pq"
 	"github.com/lib/pq/oid"
 	"github.com/liquidm/llsr/decoderbufs"
 )
 
+var (
+	ErrClientClosed       = errors.New("llsr: Client is closed")
+	ErrInvalidBufferSize  = errors.New("llsr: Buffer size must not be negative")
+	ErrInvalidEventPolicy = errors.New("llsr: PauseOnSchemaChange requires EventsBlock policy")
+	ErrUnchangedValues    = errors.New("llsr: Unable to read values of unchanged columns")
+)
+
 //Converter is used to conver raw RowMessage structs into app specific data.
 type Converter interface {
 	//Converts RowMessage into app specific data.
 	Convert(*decoderbufs.RowMessage, ValuesMap) interface{}
 }
 
+//CatalogConverter is an optional extension of Converter. When Converter implements it, Client calls ConvertWithCatalog instead of Convert.
+type CatalogConverter interface {
+	//Converts RowMessage into app specific data using database metadata such as enum labels.
+	ConvertWithCatalog(*decoderbufs.RowMessage, *Catalog) interface{}
+}
+
+//CheckedConverter converts RowMessage or reports why it cannot. Messages it fails to convert are passed to ClientConfig.DeadLetterHandler.
+//Client uses ClientConfig.CheckedConverter when set. It prefers ConvertChecked of Converter over Convert and ConvertWithCatalog
+//only when ClientConfig.DeadLetterHandler is set, so e.g. StructChange.Err still reaches Updates by default.
+type CheckedConverter interface {
+	//Converts RowMessage into app specific data using database metadata.
+	ConvertChecked(*decoderbufs.RowMessage, *Catalog) (interface{}, error)
+}
+
 //Client is a generic postgres llsr client. It handles Updates and Events received from Postgres binlog. You must call Close() to make sure everything is cleaned up properly.
 type Client interface {
 	//Updates are database events such as adding, updating or deleting records. Updates return type is defined by Converter.
+	//The channel is closed when stream ends, see EventEndOfStream.
 	Updates() <-chan interface{}
 	//Events are internal messages received during communication with LLSR.
+	//The channel is closed by Close after EventClosed.
 	Events() <-chan *Event
-	//Close makes sure every resources are released succesfully
+	//Resnapshot starts incremental snapshot of given table, which rows are emitted on Updates interleaved with live changes.
+	Resnapshot(table string) error
+	//Close makes sure every resources are released succesfully. It may be called more than once.
 	Close()
 }
 
@@ -34,45 +67,112 @@ type client struct {
 
 	db *sql.DB
 
+	config        *ClientConfig
 	dbConfig      *DatabaseConfig
 	slot          string
 	startPosition LogPos
 	converter     Converter
 
-	stream *Stream
-
-	stopped    bool
-	closeChan  chan struct{}
-	closedChan chan bool
-
-	valuesMap ValuesMap
+	lock   sync.Mutex
+	stream Source
+	closed bool
+	wg     sync.WaitGroup
+
+	//Accessed atomically
+	halted      int32
+	err         error
+	closeChan   chan struct{}
+	updatesOnce sync.Once
+	pool        *convertPool
+
+	catalog           *Catalog
+	catalogLock       sync.RWMutex
+	catalogReloadedAt time.Time
+	//Last column shape of messages not matching cached relation, by table. Used by recvData goroutine only.
+	mismatchedShapes map[string]string
+	//Column types which catalog reload did not resolve. Used by recvData goroutine only.
+	unresolvedOIDs map[int64]bool
+
+	resnapshotLock sync.Mutex
+	resnapshot     *incrementalSnapshot
 }
 
 //Creates new Client struct
 func NewClient(dbConfig *DatabaseConfig, converter Converter, slot string, startPosition LogPos) (Client, error) {
-	valuesMap, err := loadValuesMap(dbConfig)
-	if err != nil {
-		return nil, err
+	config := NewClientConfig(dbConfig, converter, slot)
+	config.StartPosition = startPosition
+	return NewClientWithConfig(config)
+}
+
+//Creates new Client struct using given ClientConfig
+//Database may be nil when Source is set; Catalog is empty then and features requiring queries are unavailable.
+func NewClientWithConfig(config *ClientConfig) (Client, error) {
+	var db *sql.DB
+	catalog := newCatalog(nil)
+	if config.Database != nil {
+		if err := config.Database.Validate(); err != nil {
+			return nil, err
+		}
+
+		var err error
+		db = config.Database.open()
+		catalog, err = loadCatalog(db)
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+	} else if config.Source == nil || config.Snapshot != nil {
+		return nil, ErrNoDatabase
+	}
+	if config.UpdatesBuffer < 0 {
+		if db != nil {
+			db.Close()
+		}
+		return nil, ErrInvalidBufferSize
+	}
+	//Dropped SchemaChange could never be acknowledged
+	if config.PauseOnSchemaChange && config.EventPolicy == EventsDropOldest {
+		if db != nil {
+			db.Close()
+		}
+		return nil, ErrInvalidEventPolicy
+	}
+	if config.Stream != nil {
+		if err := config.Stream.Validate(); err != nil {
+			if db != nil {
+				db.Close()
+			}
+			return nil, err
+		}
 	}
 
-	db, err := sql.Open("postgres", dbConfig.ToConnectionString())
-	if err != nil {
-		return nil, err
+	//Close needs room for EventClosed
+	eventsBuffer := config.EventsBuffer
+	if eventsBuffer < 1 {
+		eventsBuffer = 1
 	}
 
 	client := &client{
-		dbConfig:      dbConfig,
+		config:        config,
+		dbConfig:      config.Database,
 		db:            db,
-		converter:     converter,
-		slot:          slot,
-		startPosition: startPosition,
-		updates:       make(chan interface{}),
-		events:        make(chan *Event),
+		converter:     config.Converter,
+		slot:          config.Slot,
+		startPosition: config.StartPosition,
+		updates:       make(chan interface{}, config.UpdatesBuffer),
+		events:        make(chan *Event, eventsBuffer),
 		closeChan:     make(chan struct{}),
-		closedChan:    make(chan bool),
-		valuesMap:     valuesMap,
+		catalog:       catalog,
+
+		catalogReloadedAt: time.Now(),
+	}
+	if config.ConvertWorkers > 1 {
+		client.pool = newConvertPool(client, config.ConvertWorkers, config.PartitionByKey)
 	}
 
+	if config.Snapshot != nil {
+		return client, client.startWithSnapshot()
+	}
 	return client, client.start()
 }
 
@@ -88,98 +188,574 @@ func (c *client) Events() <-chan *Event {
 
 //Starts client. It does not block.
 func (c *client) start() error {
-	if c.stream != nil {
-		return ErrStreamAlreadyRunning
+	source, err := c.startSource()
+	if err != nil {
+		return err
 	}
 
-	c.stopped = false
+	if !c.spawn(func() { c.run(source) }) {
+		source.Close()
+		go func() {
+			<-source.Finished()
+		}()
+	}
+	return nil
+}
 
-	c.stream = NewStream(c.dbConfig, c.slot, c.startPosition)
-	if err := c.stream.Start(); err != nil {
-		return err
+func (c *client) startSource() (Source, error) {
+	source, err := c.newSource()
+	if err != nil {
+		return nil, err
+	}
+	if err := source.Start(); err != nil {
+		return nil, err
 	}
 
-	go c.recvData()
-	go c.recvStdErr()
-	go c.recvControl()
+	c.lock.Lock()
+	c.stream = source
+	c.lock.Unlock()
+	return source, nil
+}
 
-	return nil
+func (c *client) newSource() (Source, error) {
+	if c.config.Source != nil {
+		return c.config.Source(c.startPosition)
+	}
+
+	streamConfig := c.config.Stream
+	if streamConfig == nil {
+		streamConfig = NewStreamConfig()
+	}
+	if c.config.EndPosition > 0 || (streamConfig.Metrics == nil && c.config.Metrics != nil) {
+		clientStreamConfig := *streamConfig
+		if c.config.EndPosition > 0 {
+			clientStreamConfig.EndPos = c.config.EndPosition
+		}
+		if clientStreamConfig.Metrics == nil {
+			clientStreamConfig.Metrics = c.config.Metrics
+		}
+		streamConfig = &clientStreamConfig
+	}
+	stream, err := NewStreamWithConfig(c.dbConfig, c.slot, c.startPosition, streamConfig)
+	if err != nil {
+		return nil, err
+	}
+	stream.SetRecorder(c.config.Recorder)
+	return stream, nil
 }
 
-//Stops client. It blocks untill pg_recvlogical closes.
+//Stops client. It blocks until pg_recvlogical closes and all goroutines of the client exit.
+//Then it dispatches EventClosed and closes Updates and Events channels. Events not read until then are dropped.
 func (c *client) Close() {
-	c.stopped = true
+	c.lock.Lock()
+	if c.closed {
+		c.lock.Unlock()
+		return
+	}
+	c.closed = true
+	c.lock.Unlock()
+
 	close(c.closeChan)
-	<-c.closedChan
-	c.db.Close()
+	c.wg.Wait()
+	c.closeUpdates()
+	if c.db != nil {
+		c.db.Close()
+	}
+
+	c.pushEvent(&Event{Type: EventClosed, Value: c.error()})
+	close(c.events)
+}
+
+//spawn runs f in a goroutine Close waits for. It returns false without running f when client is closed.
+func (c *client) spawn(f func()) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.closed {
+		return false
+	}
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		f()
+	}()
+	return true
+}
+
+//emit dispatches event without blocking the caller. Event is dropped when client is closed before it is read,
+//or when Events is full and EventPolicy is EventsDropOldest.
+func (c *client) emit(event *Event) {
+	if c.config.EventPolicy == EventsDropOldest {
+		c.pushEvent(event)
+		return
+	}
+	c.spawn(func() {
+		c.sendEvent(event, nil)
+	})
+}
+
+//offer dispatches event only when Events has room for it; otherwise it is dropped and counted in Metrics.
+//It is used for events which may repeat without bound, e.g. for every message or reconnect,
+//so unread events do not pile up goroutines.
+func (c *client) offer(event *Event) {
+	if c.config.EventPolicy == EventsDropOldest {
+		c.pushEvent(event)
+		return
+	}
+
+	select {
+	case c.events <- event:
+	default:
+		c.config.Metrics.dropEvent()
+	}
+}
+
+//sendEvent waits until event is sent to Events, or returns false when client is closed or done is closed first.
+func (c *client) sendEvent(event *Event, done <-chan struct{}) bool {
+	if c.config.EventPolicy == EventsDropOldest {
+		c.pushEvent(event)
+		return true
+	}
+
+	select {
+	case c.events <- event:
+		return true
+	default:
+	}
+
+	start := time.Now()
+	defer c.config.Metrics.blocked(stageEvents, start)
+	select {
+	case c.events <- event:
+		return true
+	case <-done:
+	case <-c.closeChan:
+	}
+	return false
+}
+
+//pushEvent sends event without blocking, dropping the oldest unread events to make room for it.
+func (c *client) pushEvent(event *Event) {
+	for {
+		select {
+		case c.events <- event:
+			return
+		default:
+		}
+
+		select {
+		case <-c.events:
+			c.config.Metrics.dropEvent()
+		default:
+		}
+	}
+}
+
+//closeUpdates waits for conversions in progress to be delivered, then closes Updates.
+func (c *client) closeUpdates() {
+	c.updatesOnce.Do(func() {
+		if c.pool != nil {
+			c.pool.close()
+		}
+		close(c.updates)
+	})
+}
+
+//closeStream closes current source, if any.
+func (c *client) closeStream() {
+	c.lock.Lock()
+	stream := c.stream
+	c.lock.Unlock()
+
+	if stream != nil {
+		stream.Close()
+	}
+}
+
+//run delivers messages of source and reconnects when it fails, until client is closed or stopped for good.
+//Updates are closed when it returns.
+func (c *client) run(source Source) {
+	defer c.closeUpdates()
+
+	for {
+		status, err := c.runSource(source)
+		switch status {
+		case sourceEnded:
+			c.closeUpdates()
+			if c.isHalted() {
+				return
+			}
+			c.emit(&Event{Type: EventEndOfStream, Value: c.startPosition})
+			return
+		case sourceStopped:
+			if err != nil && err != io.EOF {
+				c.setErr(err)
+			}
+			return
+		}
+
+		c.offer(&Event{Type: EventReconnect})
+		if source, err = c.startSource(); err != nil {
+			c.setErr(err)
+			c.offer(&Event{Type: EventBackendInvalidExitStatus, Value: err})
+			return
+		}
+	}
+}
+
+//Outcome of runSource.
+type sourceStatus int
+
+const (
+	//Source failed and client should reconnect.
+	sourceInterrupted sourceStatus = iota
+	//Client was closed or halted, or source failed permanently.
+	sourceStopped
+	//Source has no more messages or EndPosition was reached.
+	sourceEnded
+)
+
+//runSource delivers messages of source until it finishes. It returns once no goroutine reads from source anymore.
+func (c *client) runSource(source Source) (sourceStatus, error) {
+	sourceDone := make(chan struct{})
+	dataDone := make(chan struct{})
+	stdErrDone := make(chan struct{})
+
+	status := sourceInterrupted
+	go func() {
+		defer close(dataDone)
+		status = c.recvData(source, sourceDone)
+	}()
+	go func() {
+		defer close(stdErrDone)
+		c.recvStdErr(source, sourceDone)
+	}()
+
+	var err error
+	select {
+	case err = <-source.Finished():
+	case <-c.closeChan:
+		source.Close()
+		err = <-source.Finished()
+	}
+	//Release goroutines of source which stopped by itself
+	source.Close()
+
+	if err != nil && err != io.EOF {
+		c.offer(&Event{Type: EventBackendInvalidExitStatus, Value: err})
+	}
+
+	//Source which ends closes Data after the last message, so it is read to the end. Source exits cleanly
+	//by itself only once it reached EndPosition.
+	ended := err == io.EOF || (err == nil && c.config.EndPosition > 0)
+	if !ended {
+		close(sourceDone)
+	}
+	<-dataDone
+	if ended {
+		close(sourceDone)
+	}
+	<-stdErrDone
+
+	if status == sourceInterrupted && (ended || errors.Is(err, ErrSourceFailed)) {
+		status = sourceStopped
+	}
+	//Messages converted by pool may halt client after they were read
+	if c.isHalted() {
+		status = sourceStopped
+	}
+	return status, err
 }
 
-func (c *client) recvData() {
+//recvData delivers messages of source until it is closed, ends or done is closed.
+func (c *client) recvData(source Source, done <-chan struct{}) sourceStatus {
+	var refresh <-chan time.Time
+	if c.config.ValuesMapRefreshInterval > 0 {
+		ticker := time.NewTicker(c.config.ValuesMapRefreshInterval)
+		defer ticker.Stop()
+		refresh = ticker.C
+	}
+
 	for {
 		select {
-		case data := <-c.stream.Data():
-			c.setUnchangedValues(data.GetTable(), data.GetNewTuple())
-			c.setUnchangedValues(data.GetTable(), data.GetOldTuple())
-			c.updates <- c.converter.Convert(data, c.valuesMap)
+		case <-refresh:
+			c.reloadCatalog()
+		case data, ok := <-source.Data():
+			if !ok {
+				return sourceEnded
+			}
+			if c.config.EndPosition > 0 && LogPos(data.GetLogPosition()) > c.config.EndPosition {
+				source.Close()
+				return sourceEnded
+			}
+			c.reloadUnknownTypes(data)
+			if !c.checkRelation(data) {
+				return sourceStopped
+			}
+			if !c.handleWatermark(data) {
+				var delivered bool
+				if err := c.setUnchangedValues(data); err != nil {
+					delivered = c.deadLetter(data, err)
+				} else {
+					delivered = c.deliver(data)
+				}
+				if !delivered {
+					return sourceStopped
+				}
+			}
+			if c.isHalted() {
+				return sourceStopped
+			}
 			c.startPosition = LogPos(data.GetLogPosition())
+			if c.config.EndPosition > 0 && c.startPosition >= c.config.EndPosition {
+				source.Close()
+				return sourceEnded
+			}
+		case <-done:
+			return sourceInterrupted
 		case <-c.closeChan:
-			return
+			return sourceStopped
 		}
 	}
 }
 
-func (c *client) recvStdErr() {
+func (c *client) recvStdErr(source Source, done <-chan struct{}) {
 	for {
 		select {
-		case stdErrStr := <-c.stream.ErrOut():
+		case stdErrStr := <-source.ErrOut():
 			value := stdErrStr.(string)
-			c.events <- &Event{Type: EventBackendStdErr, Value: value[:len(value)-1]}
+			if !c.sendEvent(&Event{Type: EventBackendStdErr, Value: value[:len(value)-1]}, done) {
+				return
+			}
+		case <-done:
+			return
 		case <-c.closeChan:
 			return
 		}
 	}
 }
 
-func (c *client) recvControl() {
-	for {
+//unknownTypes returns column types of data missing in ValuesMap, except ones which reload did not resolve before.
+func (c *client) unknownTypes(data *decoderbufs.RowMessage) []int64 {
+	var unknown []int64
+	for _, tuple := range [][]*decoderbufs.DatumMessage{data.GetNewTuple(), data.GetOldTuple()} {
+		for _, msg := range tuple {
+			if msg.ColumnType != nil && !c.unresolvedOIDs[msg.GetColumnType()] && !c.catalog.ValuesMap.Known(msg.GetColumnType()) {
+				unknown = append(unknown, msg.GetColumnType())
+			}
+		}
+	}
+	return unknown
+}
+
+//reloadUnknownTypes reloads catalog when data has column of unknown type, at most once per ValuesMapReloadInterval.
+//Types which reload did not resolve, e.g. unsupported builtin types, do not trigger reloads anymore.
+func (c *client) reloadUnknownTypes(data *decoderbufs.RowMessage) {
+	if c.config.ValuesMapReloadInterval <= 0 || time.Since(c.catalogReloadedAt) < c.config.ValuesMapReloadInterval {
+		return
+	}
+	unknown := c.unknownTypes(data)
+	if len(unknown) == 0 || !c.reloadCatalog() {
+		return
+	}
+
+	if c.unresolvedOIDs == nil {
+		c.unresolvedOIDs = make(map[int64]bool)
+	}
+	for _, columnType := range unknown {
+		if !c.catalog.ValuesMap.Known(columnType) {
+			c.unresolvedOIDs[columnType] = true
+		}
+	}
+}
+
+//checkRelation reloads cached table description when incoming tuple does not match it and reports schema change.
+//It returns false when client was closed while waiting for schema change acknowledgement.
+func (c *client) checkRelation(data *decoderbufs.RowMessage) bool {
+	relation, err := c.catalog.Relations.Get(data.GetTable())
+	if err != nil {
+		return true
+	}
+
+	complete := data.GetOp() == decoderbufs.Op_DELETE || len(relation.MissingColumns(data.GetNewTuple())) == 0
+	if complete && relation.matches(data.GetNewTuple()) && relation.matches(data.GetOldTuple()) {
+		return true
+	}
+
+	//Rows written before ALTER keep their shape; reload only once for all of them
+	shape := messageShape(data)
+	if c.mismatchedShapes == nil {
+		c.mismatchedShapes = make(map[string]string)
+	}
+	if c.mismatchedShapes[data.GetTable()] == shape {
+		return true
+	}
+	c.mismatchedShapes[data.GetTable()] = shape
+
+	c.catalog.Relations.Invalidate(data.GetTable())
+	reloaded, err := c.catalog.Relations.Get(data.GetTable())
+	if err != nil || sameColumns(relation.Columns, reloaded.Columns) {
+		return true
+	}
+
+	change := newSchemaChange(data.GetTable(), relation, reloaded)
+	c.emit(&Event{Type: EventSchemaChanged, Value: change})
+
+	if c.config.PauseOnSchemaChange {
 		select {
+		case <-change.acked:
 		case <-c.closeChan:
-			c.stream.Close()
-		case err := <-c.stream.Finished():
-			if err != nil {
-				go func() {
-					c.events <- &Event{Type: EventBackendInvalidExitStatus, Value: err}
-				}()
-			}
-			if !c.stopped {
-				defer c.reconnect()
-			} else {
-				c.closedChan <- true
-			}
-			return
+			return false
 		}
 	}
+	return true
 }
 
-func (c *client) reconnect() {
-	go func() {
-		c.events <- &Event{Type: EventReconnect}
-	}()
-	c.stream = nil
-	c.start()
+//messageShape describes operation and columns of message, so messages of the same table layout have the same shape.
+func messageShape(data *decoderbufs.RowMessage) string {
+	var shape bytes.Buffer
+	shape.WriteString(data.GetOp().String())
+	for _, tuple := range [][]*decoderbufs.DatumMessage{data.GetNewTuple(), data.GetOldTuple()} {
+		shape.WriteByte('|')
+		for _, msg := range tuple {
+			shape.WriteString(msg.GetColumnName())
+			shape.WriteByte(':')
+			shape.WriteString(strconv.FormatInt(msg.GetColumnType(), 10))
+			shape.WriteByte(',')
+		}
+	}
+	return shape.String()
+}
+
+//convert converts data with configured converter. It may be called from several goroutines at once.
+func (c *client) convert(data *decoderbufs.RowMessage) (interface{}, error) {
+	c.catalogLock.RLock()
+	defer c.catalogLock.RUnlock()
+
+	if c.config.CheckedConverter != nil {
+		return c.config.CheckedConverter.ConvertChecked(data, c.catalog)
+	}
+	if converter, ok := c.converter.(CheckedConverter); ok && c.config.DeadLetterHandler != nil {
+		return converter.ConvertChecked(data, c.catalog)
+	}
+	if converter, ok := c.converter.(CatalogConverter); ok {
+		return converter.ConvertWithCatalog(data, c.catalog), nil
+	}
+	return c.converter.Convert(data, c.catalog.ValuesMap), nil
+}
+
+//deliver converts data and sends it to Updates, or passes it to convert pool when ConvertWorkers is set.
+//It returns false when client was closed or halted.
+func (c *client) deliver(data *decoderbufs.RowMessage) bool {
+	if c.pool != nil {
+		return c.pool.submit(data)
+	}
+	value, err := c.convert(data)
+	return c.send(data, value, err)
+}
+
+//send sends converted value to Updates. Messages which could not be converted are passed to DeadLetterHandler.
+//It returns false when client was closed or halted.
+func (c *client) send(data *decoderbufs.RowMessage, value interface{}, err error) bool {
+	if err != nil {
+		return c.deadLetter(data, err)
+	}
+
+	select {
+	case c.updates <- value:
+		return true
+	default:
+	}
+
+	start := time.Now()
+	defer c.config.Metrics.blocked(stageUpdates, start)
+	select {
+	case c.updates <- value:
+		return true
+	case <-c.closeChan:
+		return false
+	}
 }
 
-func (c *client) setUnchangedValues(tableName string, msgs []*decoderbufs.DatumMessage) {
-	var id int64
+//deadLetter reports message which could not be converted and halts client if DeadLetterHandler says so.
+//It returns false when client was halted.
+func (c *client) deadLetter(data *decoderbufs.RowMessage, err error) bool {
+	letter := &DeadLetter{Message: data, LogPos: LogPos(data.GetLogPosition()), Err: err}
+	c.offer(&Event{Type: EventDeadLetter, Value: letter})
+
+	handler := c.config.DeadLetterHandler
+	if handler == nil {
+		handler = SkipDeadLetters
+	}
+	if handler.HandleDeadLetter(letter) == DeadLetterSkip {
+		return true
+	}
+
+	c.setErr(err)
+	atomic.StoreInt32(&c.halted, 1)
+	c.closeStream()
+	c.emit(&Event{Type: EventHalted, Value: letter})
+	return false
+}
+
+func (c *client) isHalted() bool {
+	return atomic.LoadInt32(&c.halted) != 0
+}
+
+//setErr records the first error which stopped client. It is reported by EventClosed.
+func (c *client) setErr(err error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.err == nil {
+		c.err = err
+	}
+}
+
+func (c *client) error() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.err
+}
+
+//reloadCatalog reloads catalog from database. It returns false when it failed.
+func (c *client) reloadCatalog() bool {
+	if c.db == nil {
+		return false
+	}
+
+	c.catalogReloadedAt = time.Now()
+
+	c.catalogLock.Lock()
+	discovered, err := c.catalog.reload(c.db)
+	c.catalogLock.Unlock()
+	if err != nil {
+		c.offer(&Event{Type: EventValuesMapReloadFailed, Value: err})
+		return false
+	}
+
+	if len(discovered) > 0 {
+		c.emit(&Event{Type: EventTypesDiscovered, Value: discovered})
+	}
+	return true
+}
+
+//setUnchangedValues reads values of unchanged TOASTed columns of data from the table.
+//Columns of rows which do not exist anymore are left unchanged.
+func (c *client) setUnchangedValues(data *decoderbufs.RowMessage) error {
+	if err := c.setUnchangedTupleValues(data.GetTable(), data.GetNewTuple()); err != nil {
+		return fmt.Errorf("%w: %v", ErrUnchangedValues, err)
+	}
+	if err := c.setUnchangedTupleValues(data.GetTable(), data.GetOldTuple()); err != nil {
+		return fmt.Errorf("%w: %v", ErrUnchangedValues, err)
+	}
+	return nil
+}
+
+func (c *client) setUnchangedTupleValues(tableName string, msgs []*decoderbufs.DatumMessage) error {
 	var unchangedColumns map[string]int
 	for i, msg := range msgs {
-		if msg.GetColumnName() == "id" {
-			id = int64(msg.GetDatumInt32())
-			if id == 0 {
-				id = int64(msg.GetDatumInt64())
-			}
-		}
-
 		if msg.GetUnchangedNoValue() {
 			if unchangedColumns == nil {
 				unchangedColumns = make(map[string]int)
@@ -188,33 +764,92 @@ func (c *client) setUnchangedValues(tableName string, msgs []*decoderbufs.DatumM
 		}
 	}
 
-	if len(unchangedColumns) > 0 && id != 0 {
-		var query bytes.Buffer
-		columns := make([]interface{}, 0, len(unchangedColumns))
-		first := true
+	if len(unchangedColumns) == 0 || c.db == nil {
+		return nil
+	}
 
-		query.WriteString("SELECT ")
-		for columnName, i := range unchangedColumns {
-			var value string
-			msgs[i].DatumString = &value
-			textOid := int64(oid.T_text)
-			msgs[i].ColumnType = &textOid
-			columns = append(columns, &value)
-			if !first {
-				query.WriteString(", ")
-			}
-			query.WriteString(columnName)
-			first = false
+	relation, err := c.catalog.Relations.Get(tableName)
+	if err != nil {
+		return err
+	}
+	keyColumns := []string{"id"}
+	if len(relation.PrimaryKey) > 0 {
+		keyColumns = relation.PrimaryKey
+	}
+
+	keyValues := make([]interface{}, 0, len(keyColumns))
+	for _, keyColumn := range keyColumns {
+		value := c.columnValue(msgs, keyColumn)
+		if value == nil {
+			return nil
+		}
+		keyValues = append(keyValues, value)
+	}
+
+	var query bytes.Buffer
+	indexes := make([]int, 0, len(unchangedColumns))
+	values := make([]string, len(unchangedColumns))
+	columns := make([]interface{}, 0, len(unchangedColumns))
+
+	query.WriteString("SELECT ")
+	for columnName, i := range unchangedColumns {
+		if len(indexes) > 0 {
+			query.WriteString(", ")
+		}
+		query.WriteString(pq.QuoteIdentifier(columnName))
+		columns = append(columns, &values[len(indexes)])
+		indexes = append(indexes, i)
+	}
+
+	query.WriteString(" FROM ")
+	query.WriteString(relation.QualifiedName)
+	for i, keyColumn := range keyColumns {
+		if i == 0 {
+			query.WriteString(" WHERE ")
+		} else {
+			query.WriteString(" AND ")
 		}
+		query.WriteString(pq.QuoteIdentifier(keyColumn))
+		query.WriteString(" = $")
+		query.WriteString(strconv.Itoa(i + 1))
+	}
 
-		query.WriteString(" FROM ")
-		query.WriteString(tableName)
-		query.WriteString(" WHERE id = ")
-		query.WriteString(strconv.Itoa(int(id)))
+	err = c.db.QueryRow(query.String(), keyValues...).Scan(columns...)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
 
-		err := c.db.QueryRow(query.String()).Scan(columns...)
-		if err != nil && err != sql.ErrNoRows {
-			panic(err)
+	textOid := int64(oid.T_text)
+	for j, i := range indexes {
+		msgs[i].DatumString = &values[j]
+		msgs[i].ColumnType = &textOid
+	}
+	return nil
+}
+
+//columnValue returns value of given column in tuple suitable as a query argument, or nil if it is not available.
+func (c *client) columnValue(msgs []*decoderbufs.DatumMessage, columnName string) interface{} {
+	for _, msg := range msgs {
+		if msg.GetColumnName() != columnName || msg.GetUnchangedNoValue() || msg.ColumnType == nil {
+			continue
+		}
+
+		value, err := c.catalog.ValuesMap.Extract(msg)
+		if err != nil || value == nil {
+			return nil
 		}
+
+		pointer := reflect.ValueOf(value)
+		if pointer.Kind() != reflect.Ptr {
+			return value
+		}
+		if pointer.IsNil() {
+			return nil
+		}
+		return pointer.Elem().Interface()
 	}
+	return nil
 }
@@ -0,0 +1,95 @@
+package llsr
+
+import (
+	"time"
+)
+
+// Default minimal time between ValuesMap reloads triggered by unknown OIDs.
+const DefaultValuesMapReloadInterval = 10 * time.Second
+
+// Default number of events buffered in Events channel.
+const DefaultEventsBuffer = 64
+
+// EventPolicy decides what Client does when Events channel is full.
+type EventPolicy int
+
+const (
+	// EventsBlock makes Client wait until events are read. Reading of pg_recvlogical stderr waits as well.
+	EventsBlock EventPolicy = iota
+	// EventsDropOldest makes Client drop the oldest unread event instead of waiting, so slow consumer of Events
+	// never holds back Updates. Dropped events are counted in Metrics. It cannot be used with PauseOnSchemaChange.
+	EventsDropOldest
+)
+
+// Configuration for Client.
+type ClientConfig struct {
+	Database      *DatabaseConfig
+	Converter     Converter
+	Slot          string
+	StartPosition LogPos
+	// EndPosition makes Client stop after delivering all messages up to and including it. Updates is closed then
+	// and EventEndOfStream is dispatched instead of reconnecting. It is passed to pg_recvlogical as --endpos.
+	// Zero streams forever.
+	EndPosition LogPos
+
+	// ValuesMapRefreshInterval makes Client reload ValuesMap periodically. Zero disables periodic reloads.
+	ValuesMapRefreshInterval time.Duration
+	// ValuesMapReloadInterval is the minimal time between reloads triggered by a message with an unknown OID.
+	// Zero disables reloading on unknown OIDs.
+	ValuesMapReloadInterval time.Duration
+
+	// PauseOnSchemaChange makes Client stop delivering updates after EventSchemaChanged until SchemaChange.Ack is called.
+	PauseOnSchemaChange bool
+
+	// Snapshot makes Client create the slot and emit current contents of selected tables before streaming changes.
+	// The slot must not exist yet. Nil disables initial snapshot.
+	Snapshot *SnapshotConfig
+
+	// WatermarkTable is the table written by Resnapshot to mark chunk boundaries in the log. It is created when missing.
+	WatermarkTable string
+	// ResnapshotChunkSize is the number of rows selected at once by Resnapshot.
+	ResnapshotChunkSize int
+
+	// CheckedConverter is used instead of Converter when set, so Converter may be nil.
+	CheckedConverter CheckedConverter
+	// DeadLetterHandler decides what happens with messages CheckedConverter failed to convert.
+	// Setting it makes Client use ConvertChecked of Converter implementing CheckedConverter as well.
+	// SkipDeadLetters is used when nil.
+	DeadLetterHandler DeadLetterHandler
+
+	// Stream configures pg_recvlogical process. Its defaults are used when nil.
+	Stream *StreamConfig
+	// Recorder receives raw frames read from pg_recvlogical. Nil disables recording.
+	Recorder *Recorder
+	// UpdatesBuffer is the number of converted updates waiting to be read from Updates.
+	// Zero makes conversion wait for every update to be read.
+	UpdatesBuffer int
+	// EventsBuffer is the number of events waiting to be read from Events. It is at least 1.
+	EventsBuffer int
+	// EventPolicy decides what happens when EventsBuffer is full.
+	EventPolicy EventPolicy
+	// ConvertWorkers is the number of goroutines converting messages concurrently. Updates are still delivered
+	// in log order. Converter must be safe for concurrent use then. Zero or one converts messages one by one.
+	ConvertWorkers int
+	// PartitionByKey makes ConvertWorkers deliver updates as soon as they are converted. Order is kept only
+	// for messages of the same table and primary key, or of the same table when it has no primary key.
+	PartitionByKey bool
+	// Metrics records time stages of Client and its Stream spent blocked and dropped events. Nil disables metrics.
+	Metrics *Metrics
+
+	// Source creates Source of messages instead of pg_recvlogical Stream, e.g. ReplaySource. Database may be nil then.
+	Source SourceFactory
+}
+
+// Creates new ClientConfig with given database, converter and slot. Other options are set to defaults.
+func NewClientConfig(dbConfig *DatabaseConfig, converter Converter, slot string) *ClientConfig {
+	return &ClientConfig{
+		Database:                dbConfig,
+		Converter:               converter,
+		Slot:                    slot,
+		ValuesMapReloadInterval: DefaultValuesMapReloadInterval,
+		WatermarkTable:          DefaultWatermarkTable,
+		ResnapshotChunkSize:     DefaultSnapshotChunkSize,
+		EventsBuffer:            DefaultEventsBuffer,
+	}
+}
@@ -0,0 +1,53 @@
+package llsr
+
+import (
+	"testing"
+)
+
+func TestNewClientConfig(t *testing.T) {
+	dbConfig := NewDatabaseConfig("database_name")
+	config := NewClientConfig(dbConfig, &DummyConverter{}, "slot_name")
+
+	if config.Database != dbConfig {
+		t.Fatal("Expected NewClientConfig to set Database attribute")
+	}
+
+	if config.Slot != "slot_name" {
+		t.Fatal("Expected NewClientConfig to set Slot attribute")
+	}
+
+	if config.StartPosition != 0 {
+		t.Fatal("Expected NewClientConfig not to set StartPosition attribute")
+	}
+
+	if config.ValuesMapRefreshInterval != 0 {
+		t.Fatal("Expected NewClientConfig not to enable periodic ValuesMap refresh")
+	}
+
+	if config.ValuesMapReloadInterval != DefaultValuesMapReloadInterval {
+		t.Fatal("Expected NewClientConfig to enable ValuesMap reloads on unknown OIDs")
+	}
+
+	if config.PauseOnSchemaChange {
+		t.Fatal("Expected NewClientConfig not to pause on schema changes")
+	}
+
+	if config.WatermarkTable != DefaultWatermarkTable || config.ResnapshotChunkSize != DefaultSnapshotChunkSize {
+		t.Fatal("Expected NewClientConfig to set incremental snapshot defaults")
+	}
+
+	if config.UpdatesBuffer != 0 || config.EventsBuffer != DefaultEventsBuffer || config.EventPolicy != EventsBlock {
+		t.Fatal("Expected NewClientConfig to set buffering defaults")
+	}
+}
+
+func TestClientRejectsPauseWithDroppedEvents(t *testing.T) {
+	config := NewClientConfig(nil, &DummyConverter{}, "llsr_test_slot")
+	config.Source = ReplaySource(nil, NewReplayConfig())
+	config.PauseOnSchemaChange = true
+	config.EventPolicy = EventsDropOldest
+
+	if _, err := NewClientWithConfig(config); err != ErrInvalidEventPolicy {
+		t.Fatalf("Expected ErrInvalidEventPolicy, got %v", err)
+	}
+}
@@ -3,6 +3,7 @@ package llsr
 import (
 	"bytes"
 	"database/sql"
+	"errors"
 	"testing"
 	"time"
 
@@ -24,6 +25,8 @@ func (*DummyConverter) Convert(change *decoderbufs.RowMessage, valuesMap ValuesM
 		buf.WriteString("UPDATE ")
 	case decoderbufs.Op_DELETE:
 		buf.WriteString("DELETE ")
+	case OpRead:
+		buf.WriteString("READ ")
 	}
 
 	buf.WriteString(change.GetTable())
@@ -150,3 +153,120 @@ func TestClientUpdates(t *testing.T) {
 		expectClientUpdate(t, client, "DELETE llsr_test_table")
 	})
 }
+
+type rejectingConverter struct{}
+
+func (rejectingConverter) ConvertChecked(msg *decoderbufs.RowMessage, catalog *Catalog) (interface{}, error) {
+	return nil, errTestConversion
+}
+
+func testReplayClient(t *testing.T, config *ClientConfig, replayConfig *ReplayConfig, positions ...uint64) Client {
+	return testRecordingClient(t, config, ReplaySource(testRecording(t, positions...), replayConfig))
+}
+
+func testRecordingClient(t *testing.T, config *ClientConfig, source SourceFactory) Client {
+	config.Source = source
+
+	c, err := NewClientWithConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func expectClosedEvents(t *testing.T, c Client) *Event {
+	var last *Event
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case event, ok := <-c.Events():
+			if !ok {
+				if last == nil || last.Type != EventClosed {
+					t.Fatalf("Expected EventClosed to be the last event, got %v", last)
+				}
+				return last
+			}
+			last = event
+		case <-timeout:
+			t.Fatal("Timeout")
+		}
+	}
+}
+
+func TestClientCloseClosesChannels(t *testing.T) {
+	replayConfig := NewReplayConfig()
+	replayConfig.Follow = true
+	c := testReplayClient(t, NewClientConfig(nil, &DummyConverter{}, "llsr_test_slot"), replayConfig, 1, 2)
+
+	expectClientUpdate(t, c, "INSERT users")
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range c.Updates() {
+		}
+	}()
+
+	c.Close()
+	c.Close()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected Updates to be closed")
+	}
+
+	if event := expectClosedEvents(t, c); event.Value != nil {
+		t.Fatalf("Expected no error, got %v", event.Value)
+	}
+}
+
+func TestClientCloseWithUnreadEvents(t *testing.T) {
+	config := NewClientConfig(nil, nil, "llsr_test_slot")
+	config.CheckedConverter = rejectingConverter{}
+	replayConfig := NewReplayConfig()
+	replayConfig.Follow = true
+	c := testReplayClient(t, config, replayConfig, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10)
+
+	time.Sleep(100 * time.Millisecond)
+
+	closed := make(chan struct{})
+	go func() {
+		c.Close()
+		close(closed)
+	}()
+
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected Close not to wait for events to be read")
+	}
+
+	expectClosedEvents(t, c)
+}
+
+func TestClientClosedEventReportsSourceFailure(t *testing.T) {
+	config := NewClientConfig(nil, &DummyConverter{}, "llsr_test_slot")
+	config.Source = ReplaySource(bytes.NewReader([]byte{0, 0, 0, 0, 0, 0, 0, 3, 1}), NewReplayConfig())
+
+	c, err := NewClientWithConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case _, ok := <-c.Updates():
+		if ok {
+			t.Fatal("Expected Updates to be closed")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timeout")
+	}
+
+	c.Close()
+
+	event := expectClosedEvents(t, c)
+	if err, ok := event.Value.(error); !ok || !errors.Is(err, ErrSourceFailed) {
+		t.Fatalf("Expected ErrSourceFailed, got %v", event.Value)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/liquidm/llsr"
+)
+
+func runLSN(args []string) error {
+	return subcommand("lsn", map[string]func([]string) error{
+		"diff":  runLSNDiff,
+		"parse": runLSNParse,
+	}, args)
+}
+
+// lsnInfo describes LSN in its textual and numeric forms.
+type lsnInfo struct {
+	LSN   string `json:"lsn"`
+	Value uint64 `json:"value"`
+	High  uint32 `json:"high"`
+	Low   uint32 `json:"low"`
+}
+
+func runLSNParse(args []string) error {
+	flags := flag.NewFlagSet("lsn parse", flag.ContinueOnError)
+	format := formatFlag(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if err := checkFormat(*format); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		return fmt.Errorf("usage: llsr lsn parse [flags] <lsn>...")
+	}
+
+	infos := make([]*lsnInfo, flags.NArg())
+	rows := make([][]string, flags.NArg())
+	for i, arg := range flags.Args() {
+		pos, err := llsr.ParseLogPos(arg)
+		if err != nil {
+			return err
+		}
+		infos[i] = &lsnInfo{LSN: pos.String(), Value: uint64(pos), High: uint32(pos >> 32), Low: uint32(pos)}
+		rows[i] = []string{infos[i].LSN, strconv.FormatUint(infos[i].Value, 10), strconv.FormatUint(uint64(infos[i].High), 10), strconv.FormatUint(uint64(infos[i].Low), 10)}
+	}
+
+	var value interface{} = infos
+	if len(infos) == 1 {
+		value = infos[0]
+	}
+	return output(os.Stdout, *format, value, []string{"LSN", "VALUE", "HIGH", "LOW"}, rows)
+}
+
+// lsnDiff is the number of WAL bytes from B to A, as computed by pg_wal_lsn_diff(A, B).
+type lsnDiff struct {
+	A     string `json:"a"`
+	B     string `json:"b"`
+	Bytes int64  `json:"bytes"`
+}
+
+func runLSNDiff(args []string) error {
+	flags := flag.NewFlagSet("lsn diff", flag.ContinueOnError)
+	format := formatFlag(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if err := checkFormat(*format); err != nil {
+		return err
+	}
+	if flags.NArg() != 2 {
+		return fmt.Errorf("usage: llsr lsn diff [flags] <a> <b>")
+	}
+
+	a, err := llsr.ParseLogPos(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	b, err := llsr.ParseLogPos(flags.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	diff := &lsnDiff{A: a.String(), B: b.String(), Bytes: a.Diff(b)}
+	return output(os.Stdout, *format, diff, []string{"A", "B", "BYTES"}, [][]string{{diff.A, diff.B, strconv.FormatInt(diff.Bytes, 10)}})
+}
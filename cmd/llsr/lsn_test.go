@@ -0,0 +1,22 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestLSNDistance(t *testing.T) {
+	lsn := "16/B374D000"
+	distance, err := lsnDistance(0x16B374D848, &lsn)
+	if err != nil || distance == nil || *distance != 0x848 {
+		t.Fatalf("Expected distance 0x848, got %v, %v", distance, err)
+	}
+
+	if distance, err := lsnDistance(0x16B374D848, nil); err != nil || distance != nil {
+		t.Fatalf("Expected nil distance, got %v, %v", distance, err)
+	}
+
+	invalid := "foo"
+	if _, err := lsnDistance(0x16B374D848, &invalid); err == nil {
+		t.Fatal("Expected invalid LSN to be rejected")
+	}
+}
@@ -0,0 +1,144 @@
+/*
+Command llsr inspects PostgreSQL logical replication slots using decoderbufs.
+
+Usage:
+
+	llsr <command> [flags]
+
+Commands:
+
+	tail    print changes produced by a slot
+	slot    create, drop, list and inspect replication slots
+	lsn     parse LSNs and compute WAL distance between them
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/liquidm/llsr"
+)
+
+type command struct {
+	usage string
+	run   func(args []string) error
+}
+
+var commands = map[string]*command{
+	"tail": {usage: "print changes produced by a slot", run: runTail},
+	"slot": {usage: "create, drop, list and inspect replication slots", run: runSlot},
+	"lsn":  {usage: "parse LSNs and compute WAL distance between them", run: runLSN},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "llsr: unknown command %q\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+
+	if err := cmd.run(os.Args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "llsr %s: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(os.Stderr, "Usage: llsr <command> [flags]")
+	fmt.Fprintln(os.Stderr, "\nCommands:")
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-8s%s\n", name, commands[name].usage)
+	}
+}
+
+// databaseFlags holds connection flags. Settings not given as flags are taken from PG* environment variables.
+type databaseFlags struct {
+	flags       *flag.FlagSet
+	url         string
+	dbname      string
+	user        string
+	host        string
+	port        int
+	sslmode     string
+	sslrootcert string
+	sslcert     string
+	sslkey      string
+}
+
+// newDatabaseFlags registers connection flags in flags.
+func newDatabaseFlags(flags *flag.FlagSet) *databaseFlags {
+	d := &databaseFlags{flags: flags}
+	flags.StringVar(&d.url, "url", "", "connection URL, e.g. postgres://user@host:5432/database?sslmode=require")
+	flags.StringVar(&d.dbname, "dbname", "", "database name (default $PGDATABASE)")
+	flags.StringVar(&d.user, "user", "", "database user (default $PGUSER or postgres)")
+	flags.StringVar(&d.host, "host", "", "database host (default $PGHOST)")
+	flags.IntVar(&d.port, "port", 0, "database port (default $PGPORT)")
+	flags.StringVar(&d.sslrootcert, "sslrootcert", "", "CA certificates file (default $PGSSLROOTCERT)")
+	flags.StringVar(&d.sslcert, "sslcert", "", "client certificate file (default $PGSSLCERT)")
+	flags.StringVar(&d.sslkey, "sslkey", "", "client private key file (default $PGSSLKEY)")
+	flags.StringVar(&d.sslmode, "sslmode", "", "SSL mode: disable, require, verify-ca or verify-full (default $PGSSLMODE or disable)")
+	return d
+}
+
+// config resolves connection settings once flags are parsed.
+func (d *databaseFlags) config() (*llsr.DatabaseConfig, error) {
+	config, err := llsr.DatabaseConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	if d.url != "" {
+		if config, err = llsr.ParseDatabaseURL(d.url); err != nil {
+			return nil, err
+		}
+	}
+
+	d.flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "dbname":
+			config.Database = d.dbname
+		case "user":
+			config.User = d.user
+		case "host":
+			config.Host = d.host
+		case "port":
+			config.Port = d.port
+		case "sslmode":
+			config.SSLMode = d.sslmode
+		case "sslrootcert":
+			config.SSLRootCert = d.sslrootcert
+		case "sslcert":
+			config.SSLCert = d.sslcert
+		case "sslkey":
+			config.SSLKey = d.sslkey
+		}
+	})
+	return config, config.Validate()
+}
+
+// stringsFlag is a flag which may be repeated.
+type stringsFlag []string
+
+func (s *stringsFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringsFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
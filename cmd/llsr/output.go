@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// formatFlag registers -format flag of commands printing table or JSON output.
+func formatFlag(flags *flag.FlagSet) *string {
+	return flags.String("format", "table", "output format: table or json")
+}
+
+func checkFormat(format string) error {
+	if format != "table" && format != "json" {
+		return fmt.Errorf("unknown format %q", format)
+	}
+	return nil
+}
+
+// output writes value as indented JSON or rows as aligned table with given headers.
+func output(w io.Writer, format string, value interface{}, headers []string, rows [][]string) error {
+	if format == "json" {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(value)
+	}
+
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, strings.Join(headers, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(table, strings.Join(row, "\t"))
+	}
+	return table.Flush()
+}
+
+// subcommand runs one of commands selected by first argument.
+func subcommand(name string, commands map[string]func([]string) error, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: llsr %s <%s> [flags]", name, strings.Join(sortedKeys(commands), "|"))
+	}
+
+	run, ok := commands[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown subcommand %q, expected one of %s", args[0], strings.Join(sortedKeys(commands), ", "))
+	}
+	return run(args[1:])
+}
+
+func sortedKeys(commands map[string]func([]string) error) []string {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func nullString(value *string) string {
+	if value == nil {
+		return "-"
+	}
+	return *value
+}
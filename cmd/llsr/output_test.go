@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestOutputTable(t *testing.T) {
+	var buf bytes.Buffer
+	err := output(&buf, "table", nil, []string{"SLOT", "LSN"}, [][]string{{"my_slot", "16/B374D848"}, {"other", "0/1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "SLOT     LSN\nmy_slot  16/B374D848\nother    0/1\n"
+	if buf.String() != expected {
+		t.Fatalf("Expected:\n%s\nGot:\n%s", expected, buf.String())
+	}
+}
+
+func TestOutputJSON(t *testing.T) {
+	var buf bytes.Buffer
+	err := output(&buf, "json", &lsnDiff{A: "0/2", B: "0/1", Bytes: 1}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "{\n  \"a\": \"0/2\",\n  \"b\": \"0/1\",\n  \"bytes\": 1\n}\n"
+	if buf.String() != expected {
+		t.Fatalf("Expected:\n%s\nGot:\n%s", expected, buf.String())
+	}
+}
+
+func TestCheckFormat(t *testing.T) {
+	for _, format := range []string{"table", "json"} {
+		if err := checkFormat(format); err != nil {
+			t.Fatalf("Expected %s to be accepted, got %v", format, err)
+		}
+	}
+	if err := checkFormat("yaml"); err == nil {
+		t.Fatal("Expected yaml to be rejected")
+	}
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/liquidm/llsr"
+)
+
+func runSlot(args []string) error {
+	return subcommand("slot", map[string]func([]string) error{
+		"create": runSlotCreate,
+		"drop":   runSlotDrop,
+		"list":   runSlotList,
+		"status": runSlotStatus,
+	}, args)
+}
+
+// slotInfo describes replication slot as reported by pg_replication_slots.
+type slotInfo struct {
+	Name              string  `json:"slot_name"`
+	Plugin            *string `json:"plugin"`
+	Type              string  `json:"slot_type"`
+	Database          *string `json:"database"`
+	Active            bool    `json:"active"`
+	RestartLSN        *string `json:"restart_lsn"`
+	ConfirmedFlushLSN *string `json:"confirmed_flush_lsn"`
+}
+
+// slotStatus extends slotInfo with distance of slot positions from current WAL position.
+type slotStatus struct {
+	*slotInfo
+	CurrentLSN    string `json:"current_lsn"`
+	LagBytes      *int64 `json:"lag_bytes"`
+	RetainedBytes *int64 `json:"retained_bytes"`
+}
+
+const slotQuery = "SELECT slot_name, plugin, slot_type, database, active, restart_lsn::text, confirmed_flush_lsn::text FROM pg_replication_slots"
+
+// slotFlags parses flags of slot subcommands which take slot name as the only argument.
+func slotFlags(name string, args []string, setup func(*flag.FlagSet)) (*sql.DB, string, string, error) {
+	flags := flag.NewFlagSet("slot "+name, flag.ContinueOnError)
+	dbFlags := newDatabaseFlags(flags)
+	format := formatFlag(flags)
+	if setup != nil {
+		setup(flags)
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, "", "", err
+	}
+	if err := checkFormat(*format); err != nil {
+		return nil, "", "", err
+	}
+
+	var slot string
+	if name != "list" {
+		if flags.NArg() != 1 {
+			return nil, "", "", fmt.Errorf("usage: llsr slot %s [flags] <slot>", name)
+		}
+		slot = flags.Arg(0)
+	}
+
+	dbConfig, err := dbFlags.config()
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	return dbConfig.Open(), slot, *format, nil
+}
+
+func runSlotCreate(args []string) error {
+	var plugin string
+	db, slot, format, err := slotFlags("create", args, func(flags *flag.FlagSet) {
+		flags.StringVar(&plugin, "plugin", "decoderbufs", "output plugin")
+	})
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var created struct {
+		Name string `json:"slot_name"`
+		LSN  string `json:"lsn"`
+	}
+	err = db.QueryRow("SELECT slot_name, lsn::text FROM pg_create_logical_replication_slot($1, $2)", slot, plugin).Scan(&created.Name, &created.LSN)
+	if err != nil {
+		return err
+	}
+
+	return output(os.Stdout, format, created, []string{"SLOT", "LSN"}, [][]string{{created.Name, created.LSN}})
+}
+
+func runSlotDrop(args []string) error {
+	db, slot, format, err := slotFlags("drop", args, nil)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if _, err := db.Exec("SELECT pg_drop_replication_slot($1)", slot); err != nil {
+		return err
+	}
+
+	dropped := map[string]string{"dropped": slot}
+	return output(os.Stdout, format, dropped, []string{"DROPPED"}, [][]string{{slot}})
+}
+
+func runSlotList(args []string) error {
+	db, _, format, err := slotFlags("list", args, nil)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	slots, err := querySlots(db, slotQuery+" ORDER BY slot_name")
+	if err != nil {
+		return err
+	}
+
+	rows := make([][]string, len(slots))
+	for i, slot := range slots {
+		rows[i] = []string{slot.Name, nullString(slot.Plugin), slot.Type, nullString(slot.Database), strconv.FormatBool(slot.Active), nullString(slot.RestartLSN), nullString(slot.ConfirmedFlushLSN)}
+	}
+	return output(os.Stdout, format, slots, []string{"SLOT", "PLUGIN", "TYPE", "DATABASE", "ACTIVE", "RESTART LSN", "CONFIRMED FLUSH LSN"}, rows)
+}
+
+func runSlotStatus(args []string) error {
+	db, name, format, err := slotFlags("status", args, nil)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	slots, err := querySlots(db, slotQuery+" WHERE slot_name = $1", name)
+	if err != nil {
+		return err
+	}
+	if len(slots) == 0 {
+		return fmt.Errorf("slot %q does not exist", name)
+	}
+
+	status := &slotStatus{slotInfo: slots[0]}
+	if status.CurrentLSN, err = currentWALPosition(db); err != nil {
+		return err
+	}
+	current, err := llsr.ParseLogPos(status.CurrentLSN)
+	if err != nil {
+		return err
+	}
+	if status.LagBytes, err = lsnDistance(current, status.ConfirmedFlushLSN); err != nil {
+		return err
+	}
+	if status.RetainedBytes, err = lsnDistance(current, status.RestartLSN); err != nil {
+		return err
+	}
+
+	rows := [][]string{
+		{"slot_name", status.Name},
+		{"plugin", nullString(status.Plugin)},
+		{"database", nullString(status.Database)},
+		{"active", strconv.FormatBool(status.Active)},
+		{"restart_lsn", nullString(status.RestartLSN)},
+		{"confirmed_flush_lsn", nullString(status.ConfirmedFlushLSN)},
+		{"current_lsn", status.CurrentLSN},
+		{"lag_bytes", nullInt(status.LagBytes)},
+		{"retained_bytes", nullInt(status.RetainedBytes)},
+	}
+	return output(os.Stdout, format, status, []string{"FIELD", "VALUE"}, rows)
+}
+
+// currentWALPosition returns current WAL write position. The function was renamed in PostgreSQL 10.
+func currentWALPosition(db *sql.DB) (string, error) {
+	var version int
+	if err := db.QueryRow("SELECT current_setting('server_version_num')::int").Scan(&version); err != nil {
+		return "", err
+	}
+
+	query := "SELECT pg_current_wal_lsn()::text"
+	if version < 100000 {
+		query = "SELECT pg_current_xlog_location()::text"
+	}
+
+	var lsn string
+	err := db.QueryRow(query).Scan(&lsn)
+	return lsn, err
+}
+
+func querySlots(db *sql.DB, query string, args ...interface{}) ([]*slotInfo, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	slots := make([]*slotInfo, 0)
+	for rows.Next() {
+		slot := &slotInfo{}
+		if err := rows.Scan(&slot.Name, &slot.Plugin, &slot.Type, &slot.Database, &slot.Active, &slot.RestartLSN, &slot.ConfirmedFlushLSN); err != nil {
+			return nil, err
+		}
+		slots = append(slots, slot)
+	}
+	return slots, rows.Err()
+}
+
+// lsnDistance returns number of WAL bytes between current position and lsn, or nil if lsn is unknown.
+func lsnDistance(current llsr.LogPos, lsn *string) (*int64, error) {
+	if lsn == nil {
+		return nil, nil
+	}
+	pos, err := llsr.ParseLogPos(*lsn)
+	if err != nil {
+		return nil, err
+	}
+	distance := current.Diff(pos)
+	return &distance, nil
+}
+
+func nullInt(value *int64) string {
+	if value == nil {
+		return "-"
+	}
+	return strconv.FormatInt(*value, 10)
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"reflect"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/liquidm/llsr"
+	"github.com/liquidm/llsr/decoderbufs"
+)
+
+func runTail(args []string) error {
+	flags := flag.NewFlagSet("tail", flag.ContinueOnError)
+	dbFlags := newDatabaseFlags(flags)
+	slot := flags.String("slot", "", "replication slot name (required)")
+	start := flags.String("start", "", "LSN to start from, e.g. 16/B374D848")
+	format := flags.String("format", "pretty", "output format: pretty or json")
+	peek := flags.Bool("peek", false, "print pending changes without advancing the slot and exit")
+	limit := flags.Int("limit", 0, "number of changes after which -peek stops at the end of transaction, 0 reads all")
+	upTo := flags.String("upto", "", "LSN after which -peek stops, e.g. 16/B374D848")
+	var tables stringsFlag
+	flags.Var(&tables, "table", "print changes of given table only; may be repeated")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *slot == "" {
+		return errors.New("-slot is required")
+	}
+	if !*peek && (*limit != 0 || *upTo != "") {
+		return errors.New("-limit and -upto require -peek")
+	}
+	if *format != "pretty" && *format != "json" {
+		return fmt.Errorf("unknown format %q", *format)
+	}
+
+	dbConfig, err := dbFlags.config()
+	if err != nil {
+		return err
+	}
+
+	config := newTailConfig(dbConfig, *slot, *format, tables)
+	if *start != "" {
+		pos, err := llsr.ParseLogPos(*start)
+		if err != nil {
+			return err
+		}
+		config.StartPosition = pos
+	}
+	if *peek {
+		peekConfig := llsr.NewPeekConfig()
+		peekConfig.Limit = *limit
+		if *upTo != "" {
+			if peekConfig.UpTo, err = llsr.ParseLogPos(*upTo); err != nil {
+				return err
+			}
+		}
+		config.Source = llsr.PeekSource(dbConfig, *slot, peekConfig)
+	}
+
+	client, err := llsr.NewClientWithConfig(config)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	return tail(client, signals, os.Stdout, os.Stderr)
+}
+
+// newTailConfig returns ClientConfig printing changes of slot. Changes printer fails to format are reported
+// as EventDeadLetter.
+func newTailConfig(dbConfig *llsr.DatabaseConfig, slot, format string, tables []string) *llsr.ClientConfig {
+	config := llsr.NewClientConfig(dbConfig, newPrinter(format, tables), slot)
+	config.DeadLetterHandler = llsr.SkipDeadLetters
+	return config
+}
+
+// tail prints updates of client to out and problems to errOut until client stops or a signal is received.
+func tail(client llsr.Client, signals <-chan os.Signal, out, errOut io.Writer) error {
+	updates := client.Updates()
+	for {
+		select {
+		case update, ok := <-updates:
+			if !ok {
+				// Client stopped; EventEndOfStream or EventClosed tells why.
+				updates = nil
+				go client.Close()
+				continue
+			}
+			if line, ok := update.(string); ok {
+				fmt.Fprintln(out, line)
+			}
+		case event, ok := <-client.Events():
+			if !ok {
+				return nil
+			}
+			switch event.Type {
+			case llsr.EventEndOfStream:
+				return nil
+			case llsr.EventClosed:
+				if err, ok := event.Value.(error); ok {
+					return err
+				}
+				return nil
+			case llsr.EventDeadLetter:
+				letter := event.Value.(*llsr.DeadLetter)
+				fmt.Fprintf(errOut, "%s: cannot print change of %s: %v\n", letter.LogPos, letter.Message.GetTable(), letter.Err)
+			case llsr.EventBackendStdErr, llsr.EventBackendInvalidExitStatus, llsr.EventReconnect:
+				if event.Value != nil {
+					fmt.Fprintln(errOut, event.Value)
+				}
+			}
+		case <-signals:
+			return nil
+		}
+	}
+}
+
+// printer is a Converter formatting changes as lines of text. Changes of filtered out tables are converted to nil.
+type printer struct {
+	format string
+	tables map[string]bool
+	json   *llsr.JSONConverter
+}
+
+func newPrinter(format string, tables []string) *printer {
+	p := &printer{
+		format: format,
+		json:   llsr.NewJSONConverter(llsr.JSONConverterOptions{UnknownOIDs: llsr.UnknownOIDString}),
+	}
+	if len(tables) > 0 {
+		p.tables = make(map[string]bool, len(tables))
+		for _, table := range tables {
+			p.tables[table] = true
+		}
+	}
+	return p
+}
+
+// Convert implements llsr.Converter interface. Client uses ConvertChecked instead, see newTailConfig.
+func (p *printer) Convert(msg *decoderbufs.RowMessage, valuesMap llsr.ValuesMap) interface{} {
+	line, _ := p.ConvertChecked(msg, &llsr.Catalog{ValuesMap: valuesMap})
+	return line
+}
+
+// ConvertChecked implements llsr.CheckedConverter interface.
+func (p *printer) ConvertChecked(msg *decoderbufs.RowMessage, catalog *llsr.Catalog) (interface{}, error) {
+	if p.tables != nil && !p.tables[msg.GetTable()] {
+		return nil, nil
+	}
+
+	if p.format == "json" {
+		data, err := p.json.Encode(msg, catalog)
+		if err != nil {
+			return nil, err
+		}
+		return string(data), nil
+	}
+	return p.pretty(msg, catalog.ValuesMap), nil
+}
+
+// pretty formats change as e.g. `16/B374D848 2020-06-01T12:00:00Z UPDATE users id=1 name="foo" (old: id=1 name="bar")`.
+func (p *printer) pretty(msg *decoderbufs.RowMessage, valuesMap llsr.ValuesMap) string {
+	var line strings.Builder
+	line.WriteString(llsr.LogPos(msg.GetLogPosition()).String())
+	if msg.GetCommitTime() > 0 {
+		usec := int64(msg.GetCommitTime())
+		line.WriteString(" ")
+		line.WriteString(time.Unix(usec/1e6, (usec%1e6)*1e3).UTC().Format(time.RFC3339Nano))
+	}
+	line.WriteString(" ")
+	line.WriteString(llsr.OpName(msg.GetOp()))
+	line.WriteString(" ")
+	line.WriteString(msg.GetTable())
+
+	if tuple := formatTuple(msg.GetNewTuple(), valuesMap); tuple != "" {
+		line.WriteString(" ")
+		line.WriteString(tuple)
+	}
+	if tuple := formatTuple(msg.GetOldTuple(), valuesMap); tuple != "" {
+		line.WriteString(" (old: ")
+		line.WriteString(tuple)
+		line.WriteString(")")
+	}
+	return line.String()
+}
+
+func formatTuple(tuple []*decoderbufs.DatumMessage, valuesMap llsr.ValuesMap) string {
+	columns := make([]string, 0, len(tuple))
+	for _, msg := range tuple {
+		columns = append(columns, msg.GetColumnName()+"="+formatValue(msg, valuesMap))
+	}
+	return strings.Join(columns, " ")
+}
+
+func formatValue(msg *decoderbufs.DatumMessage, valuesMap llsr.ValuesMap) string {
+	if msg.GetUnchangedNoValue() {
+		return "<unchanged>"
+	}
+	if msg.ColumnType == nil {
+		return "NULL"
+	}
+
+	value, err := valuesMap.Extract(msg)
+	if err == llsr.ErrUnknownOID {
+		if value == nil {
+			return "NULL"
+		}
+		return `\x` + hex.EncodeToString(value.([]byte))
+	}
+
+	switch v := value.(type) {
+	case nil:
+		return "NULL"
+	case *string:
+		if v != nil {
+			return fmt.Sprintf("%q", *v)
+		}
+	case *decoderbufs.Point:
+		if v != nil {
+			return fmt.Sprintf("(%g,%g)", v.GetX(), v.GetY())
+		}
+	case []byte:
+		if v != nil {
+			return `\x` + hex.EncodeToString(v)
+		}
+	default:
+		if pointer := reflect.ValueOf(v); pointer.Kind() == reflect.Ptr && !pointer.IsNil() {
+			return fmt.Sprint(pointer.Elem().Interface())
+		}
+	}
+	return "NULL"
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/liquidm/llsr"
+	"github.com/liquidm/llsr/decoderbufs"
+)
+
+func testDatum(name string, columnType int64) *decoderbufs.DatumMessage {
+	return &decoderbufs.DatumMessage{ColumnName: proto.String(name), ColumnType: proto.Int64(columnType)}
+}
+
+func testMessage() *decoderbufs.RowMessage {
+	id := testDatum("id", 23)
+	id.DatumInt32 = proto.Int32(1)
+	name := testDatum("name", 25)
+	name.DatumString = proto.String("foo")
+	oldName := testDatum("name", 25)
+	oldName.DatumString = proto.String("bar")
+	note := testDatum("note", 25)
+	status := testDatum("status", 100000)
+	status.DatumBytes = []byte("ok")
+
+	return &decoderbufs.RowMessage{
+		LogPosition: proto.Uint64(0x16B374D848),
+		CommitTime:  proto.Uint64(1590969600000000),
+		Table:       proto.String("users"),
+		Op:          decoderbufs.Op_UPDATE.Enum(),
+		NewTuple:    []*decoderbufs.DatumMessage{id, name, note, status},
+		OldTuple:    []*decoderbufs.DatumMessage{id, oldName},
+	}
+}
+
+func TestPrinterPretty(t *testing.T) {
+	line := newPrinter("pretty", nil).Convert(testMessage(), llsr.ValuesMap{})
+
+	expected := `16/B374D848 2020-06-01T00:00:00Z UPDATE users id=1 name="foo" note=NULL status=\x6f6b (old: id=1 name="bar")`
+	if line != expected {
+		t.Fatalf("Expected:\n%s\nGot:\n%s", expected, line)
+	}
+}
+
+func TestPrinterJSON(t *testing.T) {
+	line := newPrinter("json", nil).Convert(testMessage(), llsr.ValuesMap{})
+
+	expected := `{"table":"users","op":"UPDATE","lsn":"16/B374D848","commit_time":"2020-06-01T00:00:00Z","key":null,` +
+		`"before":{"id":1,"name":"bar"},"after":{"id":1,"name":"foo","note":null,"status":"ok"}}`
+	if line != expected {
+		t.Fatalf("Expected:\n%s\nGot:\n%s", expected, line)
+	}
+}
+
+func TestPrinterTableFilter(t *testing.T) {
+	p := newPrinter("pretty", []string{"orders"})
+
+	if line := p.Convert(testMessage(), llsr.ValuesMap{}); line != nil {
+		t.Fatalf("Expected users to be filtered out, got %v", line)
+	}
+
+	msg := testMessage()
+	msg.Table = proto.String("orders")
+	if line := p.Convert(msg, llsr.ValuesMap{}); line == nil {
+		t.Fatal("Expected orders to be printed")
+	}
+}
+
+func testRecording(t *testing.T, msgs ...*decoderbufs.RowMessage) *bytes.Buffer {
+	var buf bytes.Buffer
+	for _, msg := range msgs {
+		data, err := proto.Marshal(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		binary.Write(&buf, binary.BigEndian, uint64(len(data)))
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+	return &buf
+}
+
+func TestTail(t *testing.T) {
+	broken := testMessage()
+	broken.LogPosition = proto.Uint64(0x16B374D849)
+	score := testDatum("score", 701)
+	score.DatumDouble = proto.Float64(math.NaN())
+	broken.NewTuple = append(broken.NewTuple, score)
+
+	config := newTailConfig(nil, "llsr_test_slot", "json", nil)
+	config.Source = llsr.ReplaySource(testRecording(t, testMessage(), broken), llsr.NewReplayConfig())
+	client, err := llsr.NewClientWithConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	var out, errOut bytes.Buffer
+	if err := tail(client, nil, &out, &errOut); err != nil {
+		t.Fatal(err)
+	}
+
+	if lines := strings.Split(strings.TrimSpace(out.String()), "\n"); len(lines) != 1 || !strings.HasPrefix(lines[0], `{"table":"users"`) {
+		t.Fatalf("Expected a single JSON line, got %q", out.String())
+	}
+	if !strings.HasPrefix(errOut.String(), "16/B374D849: cannot print change of users:") {
+		t.Fatalf("Expected change which cannot be encoded to be reported, got %q", errOut.String())
+	}
+}
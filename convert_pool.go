@@ -0,0 +1,191 @@
+package llsr
+
+import (
+	"hash/fnv"
+	"sync"
+
+	"github.com/liquidm/llsr/decoderbufs"
+)
+
+// convertJob is a single message converted by convertPool.
+type convertJob struct {
+	data  *decoderbufs.RowMessage
+	value interface{}
+	err   error
+	done  chan struct{}
+}
+
+// convertPool converts messages on several goroutines. By default results are delivered in the order
+// messages were submitted. Partitioned pool routes messages by table and primary key instead, so only
+// messages of the same row keep their order.
+type convertPool struct {
+	client      *client
+	partitioned bool
+
+	queues []chan *convertJob
+	next   int
+	// ordered holds submitted jobs in submission order until their results are delivered.
+	ordered chan *convertJob
+
+	startOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+func newConvertPool(c *client, workers int, partitioned bool) *convertPool {
+	pool := &convertPool{
+		client:      c,
+		partitioned: partitioned,
+		queues:      make([]chan *convertJob, workers),
+	}
+	for i := range pool.queues {
+		pool.queues[i] = make(chan *convertJob, 1)
+	}
+	if !partitioned {
+		pool.ordered = make(chan *convertJob, 2*workers)
+	}
+	return pool
+}
+
+// start runs pool goroutines. It is called by the first submit, so client which failed to start leaks nothing.
+// Close of the client waits for them.
+func (p *convertPool) start() {
+	for _, queue := range p.queues {
+		p.run(p.convert, queue)
+	}
+	if !p.partitioned {
+		p.run(p.collect, p.ordered)
+	}
+}
+
+func (p *convertPool) run(f func(chan *convertJob), queue chan *convertJob) {
+	p.wg.Add(1)
+	if !p.client.spawn(func() {
+		defer p.wg.Done()
+		f(queue)
+	}) {
+		p.wg.Done()
+	}
+}
+
+// close delivers remaining results and stops pool goroutines. Nothing may be submitted afterwards.
+func (p *convertPool) close() {
+	for _, queue := range p.queues {
+		close(queue)
+	}
+	if !p.partitioned {
+		close(p.ordered)
+	}
+	p.wg.Wait()
+}
+
+// submit passes data to conversion. It returns false when client was closed or halted.
+func (p *convertPool) submit(data *decoderbufs.RowMessage) bool {
+	c := p.client
+	if c.isHalted() {
+		return false
+	}
+	p.startOnce.Do(p.start)
+
+	job := &convertJob{data: data}
+	queue := p.queues[p.next]
+	if p.partitioned {
+		queue = p.queues[p.partition(data)]
+	} else {
+		job.done = make(chan struct{})
+		p.next = (p.next + 1) % len(p.queues)
+
+		select {
+		case p.ordered <- job:
+		case <-c.closeChan:
+			return false
+		}
+	}
+
+	select {
+	case queue <- job:
+		return true
+	case <-c.closeChan:
+		return false
+	}
+}
+
+// partition returns index of worker converting messages of the same table and primary key as data.
+func (p *convertPool) partition(data *decoderbufs.RowMessage) int {
+	c := p.client
+	keyColumns := []string{"id"}
+	if relation, err := c.catalog.Relations.Get(data.GetTable()); err == nil && len(relation.PrimaryKey) > 0 {
+		keyColumns = relation.PrimaryKey
+	}
+
+	tuple := data.GetNewTuple()
+	if len(tuple) == 0 {
+		tuple = data.GetOldTuple()
+	}
+
+	hash := fnv.New32a()
+	hash.Write([]byte(data.GetTable()))
+	if key, ok := c.tupleKey(tuple, keyColumns); ok {
+		hash.Write([]byte{0})
+		hash.Write([]byte(key))
+	}
+	return int(hash.Sum32() % uint32(len(p.queues)))
+}
+
+// convert converts jobs from queue. Partitioned pool delivers results right away.
+// After client is halted remaining jobs are dropped, so submit does not block.
+func (p *convertPool) convert(queue chan *convertJob) {
+	c := p.client
+	for {
+		select {
+		case job, ok := <-queue:
+			if !ok {
+				return
+			}
+			if !c.isHalted() {
+				job.value, job.err = c.convert(job.data)
+			}
+			if p.partitioned {
+				if !p.send(job) {
+					return
+				}
+			} else {
+				close(job.done)
+			}
+		case <-c.closeChan:
+			return
+		}
+	}
+}
+
+// collect delivers results of jobs in the order they were submitted.
+func (p *convertPool) collect(ordered chan *convertJob) {
+	c := p.client
+	for {
+		select {
+		case job, ok := <-ordered:
+			if !ok {
+				return
+			}
+			select {
+			case <-job.done:
+			case <-c.closeChan:
+				return
+			}
+			if !p.send(job) {
+				return
+			}
+		case <-c.closeChan:
+			return
+		}
+	}
+}
+
+// send delivers result of job. It returns false when client was closed, so no later job may be delivered.
+// Results are dropped once client is halted, but remaining jobs are still drained, so submit does not block.
+func (p *convertPool) send(job *convertJob) bool {
+	c := p.client
+	if c.isHalted() {
+		return true
+	}
+	return c.send(job.data, job.value, job.err) || c.isHalted()
+}
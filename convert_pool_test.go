@@ -0,0 +1,131 @@
+package llsr
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/liquidm/llsr/decoderbufs"
+)
+
+// slowConverter fails like failingConverter, but after a random delay, and converts other messages to themselves.
+type slowConverter struct{}
+
+func (slowConverter) ConvertChecked(msg *decoderbufs.RowMessage, catalog *Catalog) (interface{}, error) {
+	time.Sleep(time.Duration(rand.Intn(2000)) * time.Microsecond)
+	if _, err := (failingConverter{}).ConvertChecked(msg, catalog); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+func testPoolConfig(partitioned bool) *ClientConfig {
+	config := NewClientConfig(nil, nil, "llsr_test_slot")
+	config.CheckedConverter = slowConverter{}
+	config.DeadLetterHandler = HaltOnDeadLetter
+	config.ConvertWorkers = 4
+	config.PartitionByKey = partitioned
+	return config
+}
+
+func testPositions(count uint64) []uint64 {
+	positions := make([]uint64, count)
+	for i := range positions {
+		positions[i] = uint64(i + 1)
+	}
+	return positions
+}
+
+func readPoolUpdates(t *testing.T, c Client) []*decoderbufs.RowMessage {
+	var updates []*decoderbufs.RowMessage
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case update, ok := <-c.Updates():
+			if !ok {
+				return updates
+			}
+			updates = append(updates, update.(*decoderbufs.RowMessage))
+		case <-timeout:
+			t.Fatal("Timeout")
+		}
+	}
+}
+
+func TestConvertPoolKeepsOrder(t *testing.T) {
+	c := testReplayClient(t, testPoolConfig(false), NewReplayConfig(), testPositions(100)...)
+	defer c.Close()
+
+	updates := readPoolUpdates(t, c)
+	if len(updates) != 100 {
+		t.Fatalf("Expected 100 updates, got %d", len(updates))
+	}
+	for i, update := range updates {
+		if update.GetLogPosition() != uint64(i+1) {
+			t.Fatalf("Expected update %d at position %d, got %d", i, i+1, update.GetLogPosition())
+		}
+	}
+}
+
+func TestConvertPoolPartitionedKeepsKeyOrder(t *testing.T) {
+	var messages []*decoderbufs.RowMessage
+	for _, pos := range testPositions(100) {
+		messages = append(messages, testMessage(pos, "users", int32(pos%7)))
+	}
+	c := testRecordingClient(t, testPoolConfig(true), ReplaySource(testMessageRecording(t, messages...), NewReplayConfig()))
+	defer c.Close()
+
+	updates := readPoolUpdates(t, c)
+	if len(updates) != 100 {
+		t.Fatalf("Expected 100 updates, got %d", len(updates))
+	}
+	last := map[int32]uint64{}
+	for _, update := range updates {
+		id := update.GetNewTuple()[0].GetDatumInt32()
+		if update.GetLogPosition() <= last[id] {
+			t.Fatalf("Expected update of id %d at %d to follow %d", id, update.GetLogPosition(), last[id])
+		}
+		last[id] = update.GetLogPosition()
+	}
+}
+
+func TestConvertPoolHalts(t *testing.T) {
+	var messages []*decoderbufs.RowMessage
+	for _, pos := range testPositions(20) {
+		table := "users"
+		if pos == 10 {
+			table = "broken"
+		}
+		messages = append(messages, testMessage(pos, table, int32(pos)))
+	}
+	c := testRecordingClient(t, testPoolConfig(false), ReplaySource(testMessageRecording(t, messages...), NewReplayConfig()))
+
+	updates := readPoolUpdates(t, c)
+	if len(updates) != 9 || updates[8].GetLogPosition() != 9 {
+		t.Fatalf("Expected updates up to position 9, got %v", updates)
+	}
+
+	c.Close()
+	if event := expectClosedEvents(t, c); event.Value != errTestConversion {
+		t.Fatalf("Expected conversion error, got %v", event.Value)
+	}
+}
+
+func TestConvertPoolClose(t *testing.T) {
+	c := testReplayClient(t, testPoolConfig(false), NewReplayConfig(), testPositions(100)...)
+
+	expected := uint64(1)
+	for update := range c.Updates() {
+		if update.(*decoderbufs.RowMessage).GetLogPosition() != expected {
+			t.Fatalf("Expected update at %d, got %v", expected, update)
+		}
+		if expected == 10 {
+			go c.Close()
+		}
+		expected++
+	}
+
+	if event := expectClosedEvents(t, c); event.Value != nil {
+		t.Fatalf("Expected no error, got %v", event.Value)
+	}
+}
@@ -0,0 +1,100 @@
+package llsr
+
+import (
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq/oid"
+	"github.com/liquidm/llsr/decoderbufs"
+)
+
+// textDatum builds DatumMessage from textual representation of column value, filling the same
+// field decoderbufs would fill for column type. It is used for rows read with SELECT instead of
+// received from replication slot.
+func textDatum(column *Column, value sql.NullString) (*decoderbufs.DatumMessage, error) {
+	columnName := column.Name
+	columnType := int64(column.Type)
+	datum := &decoderbufs.DatumMessage{
+		ColumnName: &columnName,
+		ColumnType: &columnType,
+	}
+
+	if !value.Valid {
+		return datum, nil
+	}
+
+	text := value.String
+	switch oid.Oid(column.Type) {
+	case oid.T_bool:
+		b := text == "t" || text == "true"
+		datum.DatumBool = &b
+	case oid.T_int2, oid.T_int4:
+		i, err := strconv.ParseInt(text, 10, 32)
+		if err != nil {
+			return nil, err
+		}
+		i32 := int32(i)
+		datum.DatumInt32 = &i32
+	case oid.T_int8, oid.T_oid:
+		i, err := strconv.ParseInt(text, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		datum.DatumInt64 = &i
+	case oid.T_float4:
+		f, err := strconv.ParseFloat(text, 32)
+		if err != nil {
+			return nil, err
+		}
+		f32 := float32(f)
+		datum.DatumFloat = &f32
+	case oid.T_float8, oid.T_numeric:
+		f, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return nil, err
+		}
+		datum.DatumDouble = &f
+	case oid.T_char, oid.T_varchar, oid.T_bpchar, oid.T_text, oid.T_json, oid.T_xml, oid.T_uuid, oid.T_timestamp, oid.T_timestamptz, oid.T_date, oid.T_tstzrange:
+		datum.DatumString = &text
+	case oid.T_point:
+		var x, y float64
+		if _, err := fmt.Sscanf(text, "(%g,%g)", &x, &y); err != nil {
+			return nil, err
+		}
+		datum.DatumPoint = &decoderbufs.Point{X: &x, Y: &y}
+	case oid.T_bytea:
+		b, err := hex.DecodeString(strings.TrimPrefix(text, `\x`))
+		if err != nil {
+			return nil, err
+		}
+		datum.DatumBytes = b
+	default:
+		datum.DatumBytes = []byte(text)
+	}
+
+	return datum, nil
+}
+
+// parseTime parses textual timestamp or date as sent by decoderbufs.
+func parseTime(columnType oid.Oid, text string) (time.Time, error) {
+	layouts := []string{"2006-01-02 15:04:05.999999999"}
+	switch columnType {
+	case oid.T_timestamptz:
+		layouts = []string{"2006-01-02 15:04:05.999999999-07", "2006-01-02 15:04:05.999999999-07:00"}
+	case oid.T_date:
+		layouts = []string{"2006-01-02"}
+	}
+
+	var err error
+	for _, layout := range layouts {
+		var t time.Time
+		if t, err = time.Parse(layout, text); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
@@ -0,0 +1,76 @@
+package llsr
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+
+	"github.com/lib/pq/oid"
+)
+
+func TestTextDatum(t *testing.T) {
+	values := []struct {
+		oid      oid.Oid
+		text     string
+		expected interface{}
+	}{
+		{oid.T_bool, "t", true},
+		{oid.T_int4, "-42", int32(-42)},
+		{oid.T_int8, "8589934592", int64(8589934592)},
+		{oid.T_float4, "1.5", float32(1.5)},
+		{oid.T_numeric, "12.25", float64(12.25)},
+		{oid.T_text, "foo", "foo"},
+		{oid.T_bytea, `\x666f6f`, []byte("foo")},
+		{oid.Oid(100000), "enum_label", []byte("enum_label")},
+	}
+
+	valuesMap := ValuesMap{}
+	for _, v := range values {
+		datum, err := textDatum(&Column{Name: "column", Type: int(v.oid)}, sql.NullString{String: v.text, Valid: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if datum.GetColumnName() != "column" || datum.GetColumnType() != int64(v.oid) {
+			t.Fatalf("Expected datum to describe column. Got: %v", datum)
+		}
+
+		value, _ := valuesMap.Extract(datum)
+		if pointer := reflect.ValueOf(value); pointer.Kind() == reflect.Ptr {
+			value = pointer.Elem().Interface()
+		}
+
+		if !reflect.DeepEqual(value, v.expected) {
+			t.Fatalf("Expected %q of oid %d to be converted to %#v. Got: %#v", v.text, v.oid, v.expected, value)
+		}
+	}
+}
+
+func TestTextDatumPoint(t *testing.T) {
+	datum, err := textDatum(&Column{Name: "location", Type: int(oid.T_point)}, sql.NullString{String: "(1.5,-2)", Valid: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if datum.GetDatumPoint().GetX() != 1.5 || datum.GetDatumPoint().GetY() != -2 {
+		t.Fatalf("Expected point (1.5,-2). Got: %v", datum.GetDatumPoint())
+	}
+}
+
+func TestTextDatumNull(t *testing.T) {
+	datum, err := textDatum(&Column{Name: "column", Type: int(oid.T_int4)}, sql.NullString{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if datum.DatumInt32 != nil {
+		t.Fatalf("Expected NULL datum not to have value. Got: %v", datum)
+	}
+}
+
+func TestTextDatumInvalid(t *testing.T) {
+	_, err := textDatum(&Column{Name: "column", Type: int(oid.T_int4)}, sql.NullString{String: "foo", Valid: true})
+	if err == nil {
+		t.Fatal("Expected textDatum to return error for invalid integer")
+	}
+}
@@ -0,0 +1,92 @@
+package llsr
+
+import (
+	"os"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/liquidm/llsr/decoderbufs"
+)
+
+// DeadLetterAction tells Client what to do after a message could not be converted.
+type DeadLetterAction int
+
+const (
+	// Message is dropped and streaming continues.
+	DeadLetterSkip DeadLetterAction = iota
+	// Client stops delivering updates and dispatches EventHalted. Close must still be called.
+	DeadLetterHalt
+)
+
+// DeadLetter is a message which CheckedConverter failed to convert, or whose unchanged column values could not be
+// read (Err wraps ErrUnchangedValues).
+type DeadLetter struct {
+	Message *decoderbufs.RowMessage
+	LogPos  LogPos
+	Err     error
+}
+
+// DeadLetterHandler decides what happens with messages which could not be converted.
+type DeadLetterHandler interface {
+	HandleDeadLetter(*DeadLetter) DeadLetterAction
+}
+
+// DeadLetterHandlerFunc adapts a function to DeadLetterHandler.
+type DeadLetterHandlerFunc func(*DeadLetter) DeadLetterAction
+
+// HandleDeadLetter calls f.
+func (f DeadLetterHandlerFunc) HandleDeadLetter(letter *DeadLetter) DeadLetterAction {
+	return f(letter)
+}
+
+var (
+	// SkipDeadLetters drops messages which could not be converted. It is used when ClientConfig.DeadLetterHandler is nil.
+	SkipDeadLetters = DeadLetterHandlerFunc(func(*DeadLetter) DeadLetterAction { return DeadLetterSkip })
+	// HaltOnDeadLetter stops Client on the first message which could not be converted.
+	HaltOnDeadLetter = DeadLetterHandlerFunc(func(*DeadLetter) DeadLetterAction { return DeadLetterHalt })
+)
+
+// DeadLetterFile appends messages which could not be converted to a local file. Messages are written
+// in the same framing pg_recvlogical uses for decoderbufs output, so the file can be replayed once the
+// converter is fixed. Conversion errors are not stored; they are reported with EventDeadLetter.
+type DeadLetterFile struct {
+	// Action is returned after message was written. DeadLetterHalt is returned whenever writing fails.
+	Action DeadLetterAction
+
+	lock sync.Mutex
+	file *os.File
+}
+
+// Creates new DeadLetterFile appending to file at path. The file is created when missing.
+func NewDeadLetterFile(path string, action DeadLetterAction) (*DeadLetterFile, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DeadLetterFile{Action: action, file: file}, nil
+}
+
+// HandleDeadLetter implements DeadLetterHandler interface.
+func (f *DeadLetterFile) HandleDeadLetter(letter *DeadLetter) DeadLetterAction {
+	data, err := proto.Marshal(letter.Message)
+	if err != nil {
+		return DeadLetterHalt
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if err := writeFrame(f.file, data); err != nil {
+		return DeadLetterHalt
+	}
+	return f.Action
+}
+
+// Close closes underlying file.
+func (f *DeadLetterFile) Close() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	return f.file.Close()
+}
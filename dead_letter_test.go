@@ -0,0 +1,150 @@
+package llsr
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/liquidm/llsr/decoderbufs"
+)
+
+var errTestConversion = errors.New("conversion failed")
+
+type failingConverter struct{}
+
+func (failingConverter) ConvertChecked(msg *decoderbufs.RowMessage, catalog *Catalog) (interface{}, error) {
+	if msg.GetTable() == "broken" {
+		return nil, errTestConversion
+	}
+	return msg.GetTable(), nil
+}
+
+func testDeadLetterClient(handler DeadLetterHandler) *client {
+	config := NewClientConfig(nil, nil, "llsr_test_slot")
+	config.CheckedConverter = failingConverter{}
+	config.DeadLetterHandler = handler
+
+	return &client{
+		config:    config,
+		catalog:   &Catalog{ValuesMap: make(ValuesMap)},
+		updates:   make(chan interface{}, 10),
+		events:    make(chan *Event, 10),
+		closeChan: make(chan struct{}),
+	}
+}
+
+func TestDeliverSkipsDeadLetters(t *testing.T) {
+	c := testDeadLetterClient(nil)
+
+	if !c.deliver(testMessage(42, "broken", 1)) {
+		t.Fatal("Expected client to continue after skipped message")
+	}
+	if !c.deliver(testMessage(42, "users", 1)) {
+		t.Fatal("Expected message to be delivered")
+	}
+
+	if update := <-c.updates; update != "users" {
+		t.Fatalf("Expected users update, got %v", update)
+	}
+
+	event := <-c.events
+	if event.Type != EventDeadLetter {
+		t.Fatalf("Expected EventDeadLetter, got %v", event.Type)
+	}
+	letter := event.Value.(*DeadLetter)
+	if letter.Err != errTestConversion || letter.LogPos != 42 || letter.Message.GetTable() != "broken" {
+		t.Fatalf("Unexpected dead letter %+v", letter)
+	}
+	if c.isHalted() {
+		t.Fatal("Expected client not to be halted")
+	}
+}
+
+func TestDeliverHaltsOnDeadLetter(t *testing.T) {
+	c := testDeadLetterClient(HaltOnDeadLetter)
+
+	if c.deliver(testMessage(42, "broken", 1)) {
+		t.Fatal("Expected client to halt")
+	}
+	if !c.isHalted() || c.err != errTestConversion {
+		t.Fatal("Expected client to be halted with conversion error")
+	}
+
+	seen := make(map[EventType]bool)
+	for i := 0; i < 2; i++ {
+		seen[(<-c.events).Type] = true
+	}
+	if !seen[EventDeadLetter] || !seen[EventHalted] {
+		t.Fatalf("Expected EventDeadLetter and EventHalted, got %v", seen)
+	}
+}
+
+func TestDeadLetterFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dead_letters")
+
+	handler, err := NewDeadLetterFile(path, DeadLetterSkip)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := testDeadLetterClient(handler)
+	for _, table := range []string{"broken", "users", "broken"} {
+		if !c.deliver(testMessage(42, table, 1)) {
+			t.Fatal("Expected client to continue")
+		}
+	}
+	if err := handler.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	for i := 0; i < 2; i++ {
+		data, err := readFrame(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg := &decoderbufs.RowMessage{}
+		if err := proto.Unmarshal(data, msg); err != nil {
+			t.Fatal(err)
+		}
+		if msg.GetTable() != "broken" || msg.GetLogPosition() != 42 {
+			t.Fatalf("Unexpected message %v", msg)
+		}
+	}
+
+	if _, err := readFrame(file); err == nil {
+		t.Fatal("Expected only two dead letters")
+	}
+}
+
+func TestSetUnchangedValuesReportsQueryError(t *testing.T) {
+	db, err := sql.Open("postgres", "host=127.0.0.1 port=1 sslmode=disable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	c := testDeadLetterClient(nil)
+	c.db = db
+	c.catalog.Relations = newRelationCache(db)
+	c.catalog.Relations.relations["users"] = testRelation()
+
+	msg := testMessage(42, "users", 1)
+	msg.NewTuple[1].DatumString = nil
+	msg.NewTuple[1].UnchangedNoValue = proto.Bool(true)
+
+	if err := c.setUnchangedValues(msg); !errors.Is(err, ErrUnchangedValues) {
+		t.Fatalf("Expected ErrUnchangedValues, got %v", err)
+	}
+	if msg.NewTuple[1].DatumString != nil || msg.NewTuple[1].GetColumnType() != 25 {
+		t.Fatalf("Expected unchanged column to be left as is, got %v", msg.NewTuple[1])
+	}
+}
@@ -0,0 +1,324 @@
+package llsr
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/lib/pq/oid"
+	"github.com/liquidm/llsr/decoderbufs"
+)
+
+// Version reported in source.version field of Debezium envelopes.
+const DebeziumVersion = "1.2.0.Final"
+
+// Options of DebeziumConverter.
+type DebeziumConverterOptions struct {
+	// ServerName is the logical server name used in source.name and schema names.
+	ServerName string
+	// Database is reported in source.db.
+	Database string
+	// Schema is reported in source.schema. When empty, schema of the relation found in Catalog is used, or "public".
+	Schema string
+	// IncludeSchema wraps payload in Kafka Connect envelope with schema section derived from column types.
+	// Without it the bare payload is emitted, as Debezium does with schemas.enable=false.
+	IncludeSchema bool
+	// Clock returns time reported in ts_ms field. time.Now is used when nil.
+	Clock func() time.Time
+}
+
+// DebeziumConverter converts RowMessage into JSON encoded Debezium PostgreSQL connector change event.
+// Converted values are []byte. Messages which cannot be encoded are converted to nil by Convert; ConvertChecked reports the error.
+//
+// decoderbufs does not send transaction ids, so source.txId and source.xmin are always null.
+type DebeziumConverter struct {
+	Options DebeziumConverterOptions
+}
+
+// DebeziumSchema is Kafka Connect schema description.
+type DebeziumSchema struct {
+	Type     string            `json:"type"`
+	Fields   []*DebeziumSchema `json:"fields,omitempty"`
+	Optional bool              `json:"optional"`
+	Name     string            `json:"name,omitempty"`
+	Field    string            `json:"field,omitempty"`
+}
+
+// DebeziumSource is source section of Debezium envelope.
+type DebeziumSource struct {
+	Version   string  `json:"version"`
+	Connector string  `json:"connector"`
+	Name      string  `json:"name"`
+	TsMs      int64   `json:"ts_ms"`
+	Snapshot  string  `json:"snapshot"`
+	DB        string  `json:"db"`
+	Schema    string  `json:"schema"`
+	Table     string  `json:"table"`
+	TxID      *int64  `json:"txId"`
+	LSN       *uint64 `json:"lsn"`
+	Xmin      *int64  `json:"xmin"`
+}
+
+// DebeziumPayload is payload section of Debezium envelope.
+type DebeziumPayload struct {
+	Before map[string]interface{} `json:"before"`
+	After  map[string]interface{} `json:"after"`
+	Source *DebeziumSource        `json:"source"`
+	Op     string                 `json:"op"`
+	TsMs   int64                  `json:"ts_ms"`
+}
+
+// DebeziumEnvelope is the event produced by DebeziumConverter.
+type DebeziumEnvelope struct {
+	Schema  *DebeziumSchema  `json:"schema,omitempty"`
+	Payload *DebeziumPayload `json:"payload"`
+}
+
+var debeziumOps = map[decoderbufs.Op]string{
+	decoderbufs.Op_INSERT: "c",
+	decoderbufs.Op_UPDATE: "u",
+	decoderbufs.Op_DELETE: "d",
+	OpRead:                "r",
+}
+
+// Creates new DebeziumConverter with given options.
+func NewDebeziumConverter(options DebeziumConverterOptions) *DebeziumConverter {
+	return &DebeziumConverter{Options: options}
+}
+
+// Convert implements Converter interface.
+func (d *DebeziumConverter) Convert(msg *decoderbufs.RowMessage, valuesMap ValuesMap) interface{} {
+	return d.ConvertWithCatalog(msg, &Catalog{ValuesMap: valuesMap})
+}
+
+// ConvertWithCatalog implements CatalogConverter interface. Column nullability is taken from Catalog.Relations.
+func (d *DebeziumConverter) ConvertWithCatalog(msg *decoderbufs.RowMessage, catalog *Catalog) interface{} {
+	data, err := d.Encode(msg, catalog)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// ConvertChecked implements CheckedConverter interface.
+func (d *DebeziumConverter) ConvertChecked(msg *decoderbufs.RowMessage, catalog *Catalog) (interface{}, error) {
+	return d.Encode(msg, catalog)
+}
+
+// Encode returns JSON encoded DebeziumEnvelope for given message, or only its payload when schema is not included.
+func (d *DebeziumConverter) Encode(msg *decoderbufs.RowMessage, catalog *Catalog) ([]byte, error) {
+	envelope := d.Envelope(msg, catalog)
+	if !d.Options.IncludeSchema {
+		return json.Marshal(envelope.Payload)
+	}
+	return json.Marshal(envelope)
+}
+
+// Envelope builds DebeziumEnvelope for given message.
+func (d *DebeziumConverter) Envelope(msg *decoderbufs.RowMessage, catalog *Catalog) *DebeziumEnvelope {
+	now := time.Now
+	if d.Options.Clock != nil {
+		now = d.Options.Clock
+	}
+
+	schema := d.Options.Schema
+	if schema == "" {
+		schema = "public"
+	}
+
+	table := msg.GetTable()
+	var relation *Relation
+	if catalog.Relations != nil {
+		relation, _ = catalog.Relations.Get(table)
+	}
+	// Snapshot rows carry schema qualified table name
+	if relation != nil {
+		table = relation.Name
+		if d.Options.Schema == "" {
+			schema = relation.Schema
+		}
+	}
+
+	snapshot := "false"
+	if msg.GetOp() == OpRead {
+		snapshot = "true"
+	}
+
+	lsn := msg.GetLogPosition()
+	envelope := &DebeziumEnvelope{
+		Payload: &DebeziumPayload{
+			Before: d.tuple(msg.GetOldTuple(), catalog.ValuesMap),
+			After:  d.tuple(msg.GetNewTuple(), catalog.ValuesMap),
+			Source: &DebeziumSource{
+				Version:   DebeziumVersion,
+				Connector: "postgresql",
+				Name:      d.Options.ServerName,
+				TsMs:      int64(msg.GetCommitTime() / 1000),
+				Snapshot:  snapshot,
+				DB:        d.Options.Database,
+				Schema:    schema,
+				Table:     table,
+				LSN:       &lsn,
+			},
+			Op:   debeziumOps[msg.GetOp()],
+			TsMs: now().UnixNano() / int64(time.Millisecond),
+		},
+	}
+
+	if d.Options.IncludeSchema {
+		tuple := msg.GetNewTuple()
+		if len(tuple) == 0 {
+			tuple = msg.GetOldTuple()
+		}
+		envelope.Schema = d.schema(schema, table, tuple, relation, catalog.ValuesMap)
+	}
+
+	return envelope
+}
+
+func (d *DebeziumConverter) tuple(msgs []*decoderbufs.DatumMessage, valuesMap ValuesMap) map[string]interface{} {
+	if len(msgs) == 0 {
+		return nil
+	}
+
+	tuple := make(map[string]interface{}, len(msgs))
+	for _, msg := range msgs {
+		value, _ := jsonValue(valuesMap, msg, UnknownOIDBase64)
+		if text, ok := value.(string); ok {
+			value = debeziumTimeValue(oid.Oid(msg.GetColumnType()), text)
+		}
+		if point, ok := value.(map[string]float64); ok {
+			value = map[string]interface{}{"x": point["x"], "y": point["y"], "wkb": nil, "srid": nil}
+		}
+		tuple[msg.GetColumnName()] = value
+	}
+	return tuple
+}
+
+func (d *DebeziumConverter) schema(schemaName, table string, tuple []*decoderbufs.DatumMessage, relation *Relation, valuesMap ValuesMap) *DebeziumSchema {
+	prefix := d.Options.ServerName + "." + schemaName + "." + table
+
+	fields := make([]*DebeziumSchema, 0, len(tuple))
+	for _, msg := range tuple {
+		field := debeziumFieldSchema(oid.Oid(msg.GetColumnType()), valuesMap)
+		field.Field = msg.GetColumnName()
+		field.Optional = true
+		if relation != nil {
+			if column := relation.Column(msg.GetColumnName()); column != nil {
+				field.Optional = !column.NotNull
+			}
+		}
+		fields = append(fields, field)
+	}
+
+	value := func(field string) *DebeziumSchema {
+		return &DebeziumSchema{Type: "struct", Fields: fields, Optional: true, Name: prefix + ".Value", Field: field}
+	}
+
+	optionalInt64 := func(field string) *DebeziumSchema {
+		return &DebeziumSchema{Type: "int64", Optional: true, Field: field}
+	}
+
+	source := &DebeziumSchema{
+		Type: "struct",
+		Fields: []*DebeziumSchema{
+			{Type: "string", Field: "version"},
+			{Type: "string", Field: "connector"},
+			{Type: "string", Field: "name"},
+			{Type: "int64", Field: "ts_ms"},
+			{Type: "string", Optional: true, Name: "io.debezium.data.Enum", Field: "snapshot"},
+			{Type: "string", Field: "db"},
+			{Type: "string", Field: "schema"},
+			{Type: "string", Field: "table"},
+			optionalInt64("txId"),
+			optionalInt64("lsn"),
+			optionalInt64("xmin"),
+		},
+		Name:  "io.debezium.connector.postgresql.Source",
+		Field: "source",
+	}
+
+	return &DebeziumSchema{
+		Type: "struct",
+		Fields: []*DebeziumSchema{
+			value("before"),
+			value("after"),
+			source,
+			{Type: "string", Field: "op"},
+			optionalInt64("ts_ms"),
+		},
+		Name: prefix + ".Envelope",
+	}
+}
+
+// debeziumFieldSchema returns Kafka Connect type of column with given OID, following Debezium defaults
+// (adaptive time precision, double decimal handling).
+func debeziumFieldSchema(columnType oid.Oid, valuesMap ValuesMap) *DebeziumSchema {
+	switch columnType {
+	case oid.T_bool:
+		return &DebeziumSchema{Type: "boolean"}
+	case oid.T_int2:
+		return &DebeziumSchema{Type: "int16"}
+	case oid.T_int4:
+		return &DebeziumSchema{Type: "int32"}
+	case oid.T_int8, oid.T_oid:
+		return &DebeziumSchema{Type: "int64"}
+	case oid.T_float4:
+		return &DebeziumSchema{Type: "float"}
+	case oid.T_float8, oid.T_numeric:
+		return &DebeziumSchema{Type: "double"}
+	case oid.T_timestamp:
+		return &DebeziumSchema{Type: "int64", Name: "io.debezium.time.MicroTimestamp"}
+	case oid.T_timestamptz:
+		return &DebeziumSchema{Type: "string", Name: "io.debezium.time.ZonedTimestamp"}
+	case oid.T_date:
+		return &DebeziumSchema{Type: "int32", Name: "io.debezium.time.Date"}
+	case oid.T_uuid:
+		return &DebeziumSchema{Type: "string", Name: "io.debezium.data.Uuid"}
+	case oid.T_json:
+		return &DebeziumSchema{Type: "string", Name: "io.debezium.data.Json"}
+	case oid.T_xml:
+		return &DebeziumSchema{Type: "string", Name: "io.debezium.data.Xml"}
+	case oid.T_point:
+		return &DebeziumSchema{
+			Type: "struct",
+			Fields: []*DebeziumSchema{
+				{Type: "double", Field: "x"},
+				{Type: "double", Field: "y"},
+				{Type: "bytes", Optional: true, Field: "wkb"},
+				{Type: "int32", Optional: true, Field: "srid"},
+			},
+			Name: "io.debezium.data.geometry.Point",
+		}
+	case oid.T_bytea:
+		return &DebeziumSchema{Type: "bytes"}
+	case oid.T_char, oid.T_varchar, oid.T_bpchar, oid.T_text, oid.T_tstzrange:
+		return &DebeziumSchema{Type: "string"}
+	}
+
+	if valuesMap[int(columnType)] {
+		return &DebeziumSchema{Type: "string"}
+	}
+	return &DebeziumSchema{Type: "bytes"}
+}
+
+// debeziumTimeValue converts textual timestamps and dates into Debezium representation.
+// Other values and values which cannot be parsed are returned unchanged.
+func debeziumTimeValue(columnType oid.Oid, text string) interface{} {
+	if columnType != oid.T_timestamp && columnType != oid.T_timestamptz && columnType != oid.T_date {
+		return text
+	}
+
+	t, err := parseTime(columnType, text)
+	if err != nil {
+		return text
+	}
+
+	switch columnType {
+	case oid.T_timestamp:
+		return t.UnixNano() / int64(time.Microsecond)
+	case oid.T_timestamptz:
+		return t.UTC().Format(time.RFC3339Nano)
+	default:
+		return int32(t.Unix() / 86400)
+	}
+}
@@ -0,0 +1,93 @@
+package llsr
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/lib/pq/oid"
+	"github.com/liquidm/llsr/decoderbufs"
+)
+
+func testDebeziumConverter(includeSchema bool) *DebeziumConverter {
+	return NewDebeziumConverter(DebeziumConverterOptions{
+		ServerName:    "dbserver1",
+		Database:      "inventory",
+		IncludeSchema: includeSchema,
+		Clock: func() time.Time {
+			return time.Date(2020, 6, 1, 12, 0, 1, 0, time.UTC)
+		},
+	})
+}
+
+func TestDebeziumConverterGolden(t *testing.T) {
+	catalog := &Catalog{ValuesMap: ValuesMap{100000: true}}
+
+	messages := map[string]*decoderbufs.RowMessage{
+		"debezium_insert": testRowMessage(decoderbufs.Op_INSERT),
+		"debezium_update": testRowMessage(decoderbufs.Op_UPDATE),
+		"debezium_delete": testRowMessage(decoderbufs.Op_DELETE),
+		"debezium_read":   testRowMessage(OpRead),
+	}
+	messages["debezium_read"].NewTuple = messages["debezium_insert"].NewTuple
+
+	for name, msg := range messages {
+		data, err := testDebeziumConverter(false).Encode(msg, catalog)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expectGolden(t, name, data)
+	}
+
+	data, err := testDebeziumConverter(true).Encode(messages["debezium_insert"], catalog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectGolden(t, "debezium_insert_schema", data)
+
+	dates := testRowMessage(decoderbufs.Op_INSERT)
+	dates.NewTuple = nil
+	for column, text := range map[string]string{"epoch": "1970-01-01", "eve": "1969-12-31", "founded": "1900-01-01"} {
+		datum := testDatum(column, int64(oid.T_date))
+		datum.DatumString = proto.String(text)
+		dates.NewTuple = append(dates.NewTuple, datum)
+	}
+	encodedDates, err := testDebeziumConverter(false).Encode(dates, catalog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectGolden(t, "debezium_dates", encodedDates)
+
+	converted := testDebeziumConverter(true).Convert(messages["debezium_insert"], catalog.ValuesMap)
+	if !bytes.Equal(converted.([]byte), data) {
+		t.Fatalf("Expected Convert to return encoded envelope. Got: %s", converted)
+	}
+}
+
+func TestDebeziumTimeValues(t *testing.T) {
+	values := []struct {
+		oid      oid.Oid
+		text     string
+		expected interface{}
+	}{
+		{oid.T_timestamp, "2020-06-01 12:00:00.000001", int64(1591012800000001)},
+		{oid.T_timestamptz, "2020-06-01 14:00:00.5+02", "2020-06-01T12:00:00.5Z"},
+		{oid.T_timestamptz, "2020-06-01 17:30:00+05:30", "2020-06-01T12:00:00Z"},
+		{oid.T_date, "2020-06-01", int32(18414)},
+		{oid.T_date, "1970-01-01", int32(0)},
+		{oid.T_date, "1969-12-31", int32(-1)},
+		{oid.T_date, "infinity", "infinity"},
+		{oid.T_text, "2020-06-01", "2020-06-01"},
+	}
+
+	for _, v := range values {
+		datum := testDatum("column", int64(v.oid))
+		datum.DatumString = proto.String(v.text)
+
+		tuple := testDebeziumConverter(false).tuple([]*decoderbufs.DatumMessage{datum}, ValuesMap{})
+		if tuple["column"] != v.expected {
+			t.Fatalf("Expected %q of oid %d to be converted to %#v. Got: %#v", v.text, v.oid, v.expected, tuple["column"])
+		}
+	}
+}
@@ -7,10 +7,47 @@ const (
 	EventBackendStdErr EventType = iota
 
 	//Event dispatched when reconnecting to pg_recvlogical for some reason. Value is nil.
+	//It is dropped when Events is full, like EventBackendInvalidExitStatus and EventValuesMapReloadFailed.
 	EventReconnect
 
 	//Event dispatched when pg_recvlogical exits with error. Value is set to error returned.
 	EventBackendInvalidExitStatus
+
+	//Event dispatched when ValuesMap reload finds new value types. Value is sorted []int with discovered OIDs.
+	EventTypesDiscovered
+
+	//Event dispatched when ValuesMap could not be reloaded. Value is set to error returned.
+	EventValuesMapReloadFailed
+
+	//Event dispatched when columns of incoming tuples differ from cached table description. Value is *SchemaChange.
+	EventSchemaChanged
+
+	//Event dispatched when initial snapshot was emitted and streaming started. Value is LogPos of the slot's consistent point.
+	EventSnapshotCompleted
+
+	//Event dispatched when initial snapshot could not be read or streaming could not start after it. Value is set to error returned.
+	EventSnapshotFailed
+
+	//Event dispatched when incremental snapshot started with Resnapshot finished. Value is table name.
+	EventResnapshotCompleted
+
+	//Event dispatched when incremental snapshot started with Resnapshot failed. Value is set to error returned.
+	EventResnapshotFailed
+
+	//Event dispatched when CheckedConverter failed to convert a message or its unchanged values could not be read. Value is *DeadLetter.
+	//It is dropped when Events is full, so use DeadLetterHandler to process every such message.
+	EventDeadLetter
+
+	//Event dispatched when DeadLetterHandler halted the client. Value is *DeadLetter which caused it.
+	EventHalted
+
+	//Event dispatched when Source has no more messages, e.g. at the end of Replay, or ClientConfig.EndPosition was reached.
+	//Updates channel is closed before. Value is LogPos of the last delivered message.
+	EventEndOfStream
+
+	//Event dispatched by Close as the last one before Events channel is closed. Value is error which stopped
+	//the client before Close was called, e.g. failure of Source, or nil.
+	EventClosed
 )
 
 //Event represents event to Stream struct in Client
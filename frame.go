@@ -0,0 +1,40 @@
+package llsr
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// Frames are encoded the same way pg_recvlogical writes decoderbufs output: big endian uint64 payload
+// length, the payload and a single trailing byte.
+
+const frameTrailer = '\n'
+
+// readFrame reads single frame from r. It returns io.EOF only when r ends before a new frame starts.
+func readFrame(r io.Reader) ([]byte, error) {
+	var length uint64
+	if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, length+1)
+	if _, err := io.ReadFull(r, data); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+
+	return data[:length], nil
+}
+
+// writeFrame writes data to w as single frame.
+func writeFrame(w io.Writer, data []byte) error {
+	frame := make([]byte, 8, 8+len(data)+1)
+	binary.BigEndian.PutUint64(frame, uint64(len(data)))
+	frame = append(frame, data...)
+	frame = append(frame, frameTrailer)
+
+	_, err := w.Write(frame)
+	return err
+}
@@ -0,0 +1,42 @@
+package llsr
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestFrameRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	for _, payload := range []string{"foo", "", "bar baz"} {
+		if err := writeFrame(&buf, []byte(payload)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, expected := range []string{"foo", "", "bar baz"} {
+		data, err := readFrame(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != expected {
+			t.Fatalf("Expected %q, got %q", expected, data)
+		}
+	}
+
+	if _, err := readFrame(&buf); err != io.EOF {
+		t.Fatalf("Expected io.EOF, got %v", err)
+	}
+}
+
+func TestReadFrameTruncated(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeFrame(&buf, []byte("foobar")); err != nil {
+		t.Fatal(err)
+	}
+	buf.Truncate(10)
+
+	if _, err := readFrame(&buf); err != io.ErrUnexpectedEOF {
+		t.Fatalf("Expected io.ErrUnexpectedEOF, got %v", err)
+	}
+}
@@ -1,8 +1,10 @@
 module github.com/liquidm/llsr
 
-go 1.14
+go 1.18
 
 require (
 	github.com/golang/protobuf v1.4.2
 	github.com/lib/pq v1.7.0
 )
+
+require google.golang.org/protobuf v1.23.0 // indirect
@@ -0,0 +1,291 @@
+package llsr
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/lib/pq"
+	"github.com/liquidm/llsr/decoderbufs"
+)
+
+// Default name of table used to store incremental snapshot watermarks.
+const DefaultWatermarkTable = "llsr_watermark"
+
+var (
+	ErrResnapshotRunning = errors.New("llsr: Incremental snapshot is already running")
+	ErrNoPrimaryKey      = errors.New("llsr: Table has no primary key")
+	ErrNullPrimaryKey    = errors.New("llsr: Primary key of snapshotted row is NULL")
+)
+
+// incrementalSnapshot holds state of watermark based snapshot of single table (see DBLog paper).
+// Every chunk is selected between low and high watermark writes. Rows changed in the log between
+// these watermarks are dropped from the chunk, remaining rows are emitted when high watermark is received.
+type incrementalSnapshot struct {
+	relation *Relation
+
+	lock   sync.Mutex
+	window *watermarkWindow
+}
+
+type watermarkWindow struct {
+	low     string
+	high    string
+	open    bool
+	changed map[string]bool
+	chunk   [][]*decoderbufs.DatumMessage
+	done    chan struct{}
+}
+
+// Resnapshot starts incremental snapshot of given table. Table rows are emitted on Updates() in
+// primary key ordered chunks interleaved with live changes. It does not block; completion is
+// reported with EventResnapshotCompleted or EventResnapshotFailed.
+func (c *client) Resnapshot(table string) error {
+	relation, err := c.catalog.Relations.Get(table)
+	if err != nil {
+		return err
+	}
+	if len(relation.PrimaryKey) == 0 {
+		return ErrNoPrimaryKey
+	}
+
+	_, err = c.db.Exec("CREATE TABLE IF NOT EXISTS " + pq.QuoteIdentifier(c.config.WatermarkTable) + " (slot text PRIMARY KEY, value text NOT NULL)")
+	if err != nil {
+		return err
+	}
+
+	c.resnapshotLock.Lock()
+	defer c.resnapshotLock.Unlock()
+
+	if c.resnapshot != nil {
+		return ErrResnapshotRunning
+	}
+
+	snapshot := &incrementalSnapshot{relation: relation}
+	if !c.spawn(func() { c.runResnapshot(snapshot) }) {
+		return ErrClientClosed
+	}
+	c.resnapshot = snapshot
+
+	return nil
+}
+
+func (c *client) runResnapshot(snapshot *incrementalSnapshot) {
+	err := c.readIncrementalSnapshot(snapshot)
+
+	c.resnapshotLock.Lock()
+	c.resnapshot = nil
+	c.resnapshotLock.Unlock()
+
+	if err == ErrSnapshotInterrupted {
+		return
+	}
+
+	event := &Event{Type: EventResnapshotCompleted, Value: snapshot.relation.Name}
+	if err != nil {
+		event = &Event{Type: EventResnapshotFailed, Value: err}
+	}
+	c.emit(event)
+}
+
+func (c *client) readIncrementalSnapshot(snapshot *incrementalSnapshot) error {
+	relation := snapshot.relation
+
+	columns := make([]string, len(relation.Columns))
+	for i, column := range relation.Columns {
+		columns[i] = pq.QuoteIdentifier(column.Name) + "::text"
+	}
+	keyColumns := make([]string, len(relation.PrimaryKey))
+	keyParams := make([]string, len(relation.PrimaryKey))
+	keyIndexes := make([]int, len(relation.PrimaryKey))
+	for i, column := range relation.PrimaryKey {
+		keyColumns[i] = pq.QuoteIdentifier(column)
+		keyParams[i] = "$" + strconv.Itoa(i+1)
+		for j, relationColumn := range relation.Columns {
+			if relationColumn.Name == column {
+				keyIndexes[i] = j
+			}
+		}
+	}
+
+	chunkSize := c.config.ResnapshotChunkSize
+	if chunkSize <= 0 {
+		chunkSize = DefaultSnapshotChunkSize
+	}
+
+	selectQuery := "SELECT " + strings.Join(columns, ", ") + " FROM " + relation.QualifiedName
+	orderQuery := " ORDER BY " + strings.Join(keyColumns, ", ") + " LIMIT " + strconv.Itoa(chunkSize)
+	nextQuery := selectQuery + " WHERE (" + strings.Join(keyColumns, ", ") + ") > (" + strings.Join(keyParams, ", ") + ")" + orderQuery
+
+	query := selectQuery + orderQuery
+	var lastKey []interface{}
+	for {
+		window, err := newWatermarkWindow()
+		if err != nil {
+			return err
+		}
+
+		snapshot.lock.Lock()
+		snapshot.window = window
+		snapshot.lock.Unlock()
+
+		if err := c.writeWatermark(window.low); err != nil {
+			return err
+		}
+
+		chunk, last, err := readRows(c.db, relation, query, lastKey...)
+		if err != nil {
+			return err
+		}
+
+		snapshot.lock.Lock()
+		window.chunk = chunk
+		snapshot.lock.Unlock()
+
+		if err := c.writeWatermark(window.high); err != nil {
+			return err
+		}
+
+		select {
+		case <-window.done:
+		case <-c.closeChan:
+			return ErrSnapshotInterrupted
+		}
+
+		if len(chunk) < chunkSize {
+			return nil
+		}
+
+		// Key is passed back as text, so Postgres parses it exactly, whatever its type.
+		lastKey = make([]interface{}, len(keyIndexes))
+		for i, index := range keyIndexes {
+			if !last[index].Valid {
+				return ErrNullPrimaryKey
+			}
+			lastKey[i] = last[index].String
+		}
+		query = nextQuery
+	}
+}
+
+func newWatermarkWindow() (*watermarkWindow, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	return &watermarkWindow{
+		low:     hex.EncodeToString(id[:8]),
+		high:    hex.EncodeToString(id[8:]),
+		changed: make(map[string]bool),
+		done:    make(chan struct{}),
+	}, nil
+}
+
+func (c *client) writeWatermark(value string) error {
+	_, err := c.db.Exec("INSERT INTO "+pq.QuoteIdentifier(c.config.WatermarkTable)+" (slot, value) VALUES ($1, $2) ON CONFLICT (slot) DO UPDATE SET value = EXCLUDED.value", c.slot, value)
+	return err
+}
+
+// handleWatermark processes changes of watermark table and tracks changes of table being snapshotted.
+// It returns true if data must not be delivered to consumer.
+func (c *client) handleWatermark(data *decoderbufs.RowMessage) bool {
+	c.resnapshotLock.Lock()
+	snapshot := c.resnapshot
+	c.resnapshotLock.Unlock()
+
+	if data.GetTable() != c.config.WatermarkTable {
+		if snapshot != nil {
+			c.trackResnapshotChange(snapshot, data)
+		}
+		return false
+	}
+
+	if snapshot == nil {
+		return true
+	}
+
+	var value string
+	for _, msg := range data.GetNewTuple() {
+		if msg.GetColumnName() == "value" {
+			value = msg.GetDatumString()
+		}
+	}
+
+	snapshot.lock.Lock()
+	window := snapshot.window
+	if window == nil || (value != window.low && value != window.high) {
+		snapshot.lock.Unlock()
+		return true
+	}
+	if value == window.low {
+		window.open = true
+		snapshot.lock.Unlock()
+		return true
+	}
+
+	window.open = false
+	rows := make([][]*decoderbufs.DatumMessage, 0, len(window.chunk))
+	for _, tuple := range window.chunk {
+		if key, ok := c.tupleKey(tuple, snapshot.relation.PrimaryKey); !ok || !window.changed[key] {
+			rows = append(rows, tuple)
+		}
+	}
+	snapshot.lock.Unlock()
+
+	for _, tuple := range rows {
+		op := OpRead
+		logPosition := data.GetLogPosition()
+		tableName := snapshot.relation.QualifiedName
+		row := &decoderbufs.RowMessage{
+			LogPosition: &logPosition,
+			Table:       &tableName,
+			Op:          &op,
+			NewTuple:    tuple,
+		}
+
+		if !c.deliver(row) {
+			return true
+		}
+	}
+	close(window.done)
+
+	return true
+}
+
+// trackResnapshotChange remembers keys of snapshotted table rows changed while watermark window is open.
+func (c *client) trackResnapshotChange(snapshot *incrementalSnapshot, data *decoderbufs.RowMessage) {
+	if data.GetTable() != snapshot.relation.Name {
+		return
+	}
+
+	snapshot.lock.Lock()
+	defer snapshot.lock.Unlock()
+
+	if snapshot.window == nil || !snapshot.window.open {
+		return
+	}
+
+	for _, tuple := range [][]*decoderbufs.DatumMessage{data.GetNewTuple(), data.GetOldTuple()} {
+		if key, ok := c.tupleKey(tuple, snapshot.relation.PrimaryKey); ok {
+			snapshot.window.changed[key] = true
+		}
+	}
+}
+
+// tupleKey returns textual representation of tuple's key columns.
+func (c *client) tupleKey(tuple []*decoderbufs.DatumMessage, keyColumns []string) (string, bool) {
+	parts := make([]string, len(keyColumns))
+	for i, column := range keyColumns {
+		value := c.columnValue(tuple, column)
+		if value == nil {
+			return "", false
+		}
+		parts[i] = fmt.Sprint(value)
+	}
+	return strings.Join(parts, "\x00"), true
+}
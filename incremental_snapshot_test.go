@@ -0,0 +1,139 @@
+package llsr
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/liquidm/llsr/decoderbufs"
+)
+
+func testTuple(id int32, txt string) []*decoderbufs.DatumMessage {
+	idDatum := testDatum("id", 23)
+	idDatum.DatumInt32 = proto.Int32(id)
+	txtDatum := testDatum("txt", 25)
+	txtDatum.DatumString = proto.String(txt)
+	return []*decoderbufs.DatumMessage{idDatum, txtDatum}
+}
+
+func testWatermark(value string) *decoderbufs.RowMessage {
+	valueDatum := testDatum("value", 25)
+	valueDatum.DatumString = proto.String(value)
+	return &decoderbufs.RowMessage{
+		Table:    proto.String(DefaultWatermarkTable),
+		Op:       decoderbufs.Op_UPDATE.Enum(),
+		NewTuple: []*decoderbufs.DatumMessage{testDatum("slot", 25), valueDatum},
+	}
+}
+
+func TestHandleWatermarkDeduplicatesChunk(t *testing.T) {
+	c := &client{
+		config:    NewClientConfig(nil, &DummyConverter{}, "llsr_test_slot"),
+		converter: &DummyConverter{},
+		catalog:   &Catalog{ValuesMap: make(ValuesMap)},
+		updates:   make(chan interface{}, 10),
+		closeChan: make(chan struct{}),
+	}
+
+	window, err := newWatermarkWindow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	window.chunk = [][]*decoderbufs.DatumMessage{testTuple(1, "foo"), testTuple(2, "foo")}
+	c.resnapshot = &incrementalSnapshot{relation: testRelation(), window: window}
+
+	change := &decoderbufs.RowMessage{Table: proto.String("llsr_test_table"), Op: decoderbufs.Op_UPDATE.Enum(), NewTuple: testTuple(1, "bar")}
+
+	if c.handleWatermark(change) {
+		t.Fatal("Expected changes outside of window to be delivered")
+	}
+
+	if !c.handleWatermark(testWatermark(window.low)) {
+		t.Fatal("Expected low watermark not to be delivered")
+	}
+
+	if c.handleWatermark(change) {
+		t.Fatal("Expected changes inside of window to be delivered")
+	}
+
+	if !c.handleWatermark(testWatermark(window.high)) {
+		t.Fatal("Expected high watermark not to be delivered")
+	}
+
+	select {
+	case <-window.done:
+	default:
+		t.Fatal("Expected high watermark to finish chunk")
+	}
+
+	if len(c.updates) != 1 {
+		t.Fatalf("Expected only row not changed inside of window to be emitted. Got %d rows", len(c.updates))
+	}
+
+	if update := <-c.updates; update != "READ public.llsr_test_table" {
+		t.Fatalf("Expected chunk row to be emitted as READ. Got: %v", update)
+	}
+}
+
+func TestClientResnapshot(t *testing.T) {
+	withTestClient(t, func(t *testing.T, c Client, db *sql.DB) {
+		defer db.Exec("DROP TABLE " + DefaultWatermarkTable)
+
+		_, err := db.Exec("INSERT INTO llsr_test_table (id, txt) SELECT i, 'foo' FROM generate_series(1, 3) i")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for i := 0; i < 3; i++ {
+			expectClientUpdate(t, c, "INSERT llsr_test_table")
+		}
+
+		if err := c.Resnapshot("llsr_test_table"); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := c.Resnapshot("llsr_test_table"); err != ErrResnapshotRunning {
+			t.Fatalf("Expected second Resnapshot to return ErrResnapshotRunning. Got: %v", err)
+		}
+
+		for i := 0; i < 3; i++ {
+			expectClientUpdate(t, c, "READ public.llsr_test_table")
+		}
+
+		expectClientEvent(t, c, EventResnapshotCompleted)
+	})
+}
+
+func TestClientResnapshotExactKeys(t *testing.T) {
+	withTestConnection(t, func(t *testing.T, db *sql.DB) {
+		defer db.Exec("DROP TABLE " + DefaultWatermarkTable)
+
+		for _, query := range []string{
+			"CREATE TYPE llsr_test_mood AS ENUM ('happy', 'sad')",
+			"CREATE TABLE llsr_test_key (mood llsr_test_mood, id bigint, PRIMARY KEY (mood, id))",
+			"INSERT INTO llsr_test_key VALUES ('happy', 9007199254740993), ('happy', 9007199254740994), ('sad', 9007199254740993)",
+		} {
+			if _, err := db.Exec(query); err != nil {
+				t.Fatal(err)
+			}
+		}
+		defer db.Exec("DROP TYPE llsr_test_mood")
+		defer db.Exec("DROP TABLE llsr_test_key")
+
+		config := NewClientConfig(testConfig(), &DummyConverter{}, "llsr_test_slot")
+		config.ResnapshotChunkSize = 1
+		c, err := NewClientWithConfig(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+
+		if err := c.Resnapshot("llsr_test_key"); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 3; i++ {
+			expectClientUpdate(t, c, "READ public.llsr_test_key")
+		}
+		expectClientEvent(t, c, EventResnapshotCompleted)
+	})
+}
@@ -0,0 +1,197 @@
+package llsr
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/liquidm/llsr/decoderbufs"
+)
+
+// UnknownOIDEncoding tells how JSONConverter encodes values which ValuesMap.Extract returns with ErrUnknownOID.
+type UnknownOIDEncoding int
+
+const (
+	// Raw bytes encoded as base64 string.
+	UnknownOIDBase64 UnknownOIDEncoding = iota
+	// Raw bytes encoded as hex string.
+	UnknownOIDHex
+	// Raw bytes used as string. Useful for types which decoderbufs sends in their text form.
+	UnknownOIDString
+	// Column is left out.
+	UnknownOIDOmit
+)
+
+// Options of JSONConverter.
+type JSONConverterOptions struct {
+	// FieldName maps column names to JSON field names. Column names are used as they are when nil.
+	FieldName func(string) string
+	// OmitNulls leaves out NULL columns instead of encoding them as null.
+	OmitNulls bool
+	// UnknownOIDs tells how values of unknown types are encoded.
+	UnknownOIDs UnknownOIDEncoding
+}
+
+// JSONChange is the envelope produced by JSONConverter.
+//
+// Table is the table name as sent by decoderbufs, Op is one of INSERT, UPDATE, DELETE or READ, LSN is
+// the log position formatted with LogPos.String and CommitTime is RFC 3339 UTC time of transaction commit.
+// Before holds the old tuple (when replica identity sends one) and After the new tuple; both are null
+// when not available. Key lists primary key columns when table description is available in Catalog.
+type JSONChange struct {
+	Table      string                 `json:"table"`
+	Op         string                 `json:"op"`
+	LSN        string                 `json:"lsn"`
+	CommitTime *string                `json:"commit_time"`
+	Key        []string               `json:"key"`
+	Before     map[string]interface{} `json:"before"`
+	After      map[string]interface{} `json:"after"`
+}
+
+// JSONConverter converts RowMessage into JSON encoded JSONChange. Converted values are []byte.
+// Messages which cannot be encoded (e.g. NaN values) are converted to nil by Convert; ConvertChecked reports the error.
+type JSONConverter struct {
+	Options JSONConverterOptions
+}
+
+// Creates new JSONConverter with given options.
+func NewJSONConverter(options JSONConverterOptions) *JSONConverter {
+	return &JSONConverter{Options: options}
+}
+
+// Convert implements Converter interface.
+func (j *JSONConverter) Convert(msg *decoderbufs.RowMessage, valuesMap ValuesMap) interface{} {
+	return j.ConvertWithCatalog(msg, &Catalog{ValuesMap: valuesMap})
+}
+
+// ConvertWithCatalog implements CatalogConverter interface.
+func (j *JSONConverter) ConvertWithCatalog(msg *decoderbufs.RowMessage, catalog *Catalog) interface{} {
+	data, err := j.Encode(msg, catalog)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// ConvertChecked implements CheckedConverter interface.
+func (j *JSONConverter) ConvertChecked(msg *decoderbufs.RowMessage, catalog *Catalog) (interface{}, error) {
+	return j.Encode(msg, catalog)
+}
+
+// Encode returns JSON encoded JSONChange for given message.
+func (j *JSONConverter) Encode(msg *decoderbufs.RowMessage, catalog *Catalog) ([]byte, error) {
+	return json.Marshal(j.Change(msg, catalog))
+}
+
+// Change builds JSONChange for given message.
+func (j *JSONConverter) Change(msg *decoderbufs.RowMessage, catalog *Catalog) *JSONChange {
+	change := &JSONChange{
+		Table:      msg.GetTable(),
+		Op:         OpName(msg.GetOp()),
+		LSN:        LogPos(msg.GetLogPosition()).String(),
+		CommitTime: commitTime(msg),
+		Before:     j.tuple(msg.GetOldTuple(), catalog.ValuesMap),
+		After:      j.tuple(msg.GetNewTuple(), catalog.ValuesMap),
+	}
+
+	if catalog.Relations != nil {
+		if relation, err := catalog.Relations.Get(msg.GetTable()); err == nil {
+			change.Key = relation.PrimaryKey
+		}
+	}
+
+	return change
+}
+
+func (j *JSONConverter) tuple(msgs []*decoderbufs.DatumMessage, valuesMap ValuesMap) map[string]interface{} {
+	if len(msgs) == 0 {
+		return nil
+	}
+
+	tuple := make(map[string]interface{}, len(msgs))
+	for _, msg := range msgs {
+		value, ok := jsonValue(valuesMap, msg, j.Options.UnknownOIDs)
+		if !ok || (value == nil && j.Options.OmitNulls) {
+			continue
+		}
+
+		name := msg.GetColumnName()
+		if j.Options.FieldName != nil {
+			name = j.Options.FieldName(name)
+		}
+		tuple[name] = value
+	}
+	return tuple
+}
+
+// commitTime returns RFC 3339 formatted commit time of message or nil if message has none.
+func commitTime(msg *decoderbufs.RowMessage) *string {
+	if msg.GetCommitTime() == 0 {
+		return nil
+	}
+	usec := int64(msg.GetCommitTime())
+	formatted := time.Unix(usec/1e6, (usec%1e6)*1e3).UTC().Format(time.RFC3339Nano)
+	return &formatted
+}
+
+// jsonValue returns value of datum suitable for json.Marshal. It returns false if column should be left out.
+func jsonValue(valuesMap ValuesMap, msg *decoderbufs.DatumMessage, unknownOIDs UnknownOIDEncoding) (interface{}, bool) {
+	if msg.ColumnType == nil {
+		return nil, true
+	}
+
+	value, err := valuesMap.Extract(msg)
+	if err == ErrUnknownOID {
+		if value == nil {
+			return nil, unknownOIDs != UnknownOIDOmit
+		}
+		raw := value.([]byte)
+		switch unknownOIDs {
+		case UnknownOIDHex:
+			return hex.EncodeToString(raw), true
+		case UnknownOIDString:
+			return string(raw), true
+		case UnknownOIDOmit:
+			return nil, false
+		default:
+			return base64.StdEncoding.EncodeToString(raw), true
+		}
+	}
+
+	switch v := value.(type) {
+	case *bool:
+		if v != nil {
+			return *v, true
+		}
+	case *int32:
+		if v != nil {
+			return *v, true
+		}
+	case *int64:
+		if v != nil {
+			return *v, true
+		}
+	case *float32:
+		if v != nil {
+			return *v, true
+		}
+	case *float64:
+		if v != nil {
+			return *v, true
+		}
+	case *string:
+		if v != nil {
+			return *v, true
+		}
+	case *decoderbufs.Point:
+		if v != nil {
+			return map[string]float64{"x": v.GetX(), "y": v.GetY()}, true
+		}
+	case []byte:
+		if v != nil {
+			return v, true
+		}
+	}
+	return nil, true
+}
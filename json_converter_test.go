@@ -0,0 +1,119 @@
+package llsr
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/lib/pq/oid"
+	"github.com/liquidm/llsr/decoderbufs"
+)
+
+var updateGolden = flag.Bool("update", false, "update golden files in testdata")
+
+func expectGolden(t *testing.T, name string, data []byte) {
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, data, "", "  "); err != nil {
+		t.Fatalf("Expected valid JSON, got %s: %v", data, err)
+	}
+	indented.WriteByte('\n')
+
+	path := filepath.Join("testdata", name+".golden")
+	if *updateGolden {
+		if err := ioutil.WriteFile(path, indented.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(indented.Bytes(), expected) {
+		t.Fatalf("Expected output to match %s:\n%s\nGot:\n%s", path, expected, indented.Bytes())
+	}
+}
+
+func testRowMessage(op decoderbufs.Op) *decoderbufs.RowMessage {
+	id := testDatum("id", int64(oid.T_int4))
+	id.DatumInt32 = proto.Int32(1)
+	txt := testDatum("user_name", int64(oid.T_text))
+	txt.DatumString = proto.String("foo")
+	score := testDatum("score", int64(oid.T_float8))
+	score.DatumDouble = proto.Float64(1.5)
+	note := testDatum("note", int64(oid.T_varchar))
+	location := testDatum("location", int64(oid.T_point))
+	location.DatumPoint = &decoderbufs.Point{X: proto.Float64(1), Y: proto.Float64(2)}
+	status := testDatum("status", 100000)
+	status.DatumBytes = []byte("active")
+	unknown := testDatum("range", 100001)
+	unknown.DatumBytes = []byte("[1,2)")
+
+	tuple := []*decoderbufs.DatumMessage{id, txt, score, note, location, status, unknown}
+	msg := &decoderbufs.RowMessage{
+		CommitTime:  proto.Uint64(1591012800000001),
+		LogPosition: proto.Uint64(692097666144),
+		Table:       proto.String("users"),
+		Op:          op.Enum(),
+	}
+
+	switch op {
+	case decoderbufs.Op_INSERT:
+		msg.NewTuple = tuple
+	case decoderbufs.Op_UPDATE:
+		msg.OldTuple = tuple[:1]
+		msg.NewTuple = tuple
+	case decoderbufs.Op_DELETE:
+		msg.OldTuple = tuple[:1]
+	}
+	return msg
+}
+
+func TestJSONConverterGolden(t *testing.T) {
+	catalog := &Catalog{ValuesMap: ValuesMap{100000: true}}
+
+	converters := map[string]*JSONConverter{
+		"json_insert": NewJSONConverter(JSONConverterOptions{}),
+		"json_update": NewJSONConverter(JSONConverterOptions{}),
+		"json_delete": NewJSONConverter(JSONConverterOptions{}),
+		"json_options": NewJSONConverter(JSONConverterOptions{
+			FieldName:   strings.ToUpper,
+			OmitNulls:   true,
+			UnknownOIDs: UnknownOIDString,
+		}),
+		"json_unknown_hex":  NewJSONConverter(JSONConverterOptions{UnknownOIDs: UnknownOIDHex}),
+		"json_unknown_omit": NewJSONConverter(JSONConverterOptions{UnknownOIDs: UnknownOIDOmit}),
+	}
+	ops := map[string]decoderbufs.Op{
+		"json_update": decoderbufs.Op_UPDATE,
+		"json_delete": decoderbufs.Op_DELETE,
+	}
+
+	for name, converter := range converters {
+		data, err := converter.Encode(testRowMessage(ops[name]), catalog)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expectGolden(t, name, data)
+
+		if converted := converter.ConvertWithCatalog(testRowMessage(ops[name]), catalog); !bytes.Equal(converted.([]byte), data) {
+			t.Fatalf("Expected ConvertWithCatalog to return encoded change. Got: %s", converted)
+		}
+	}
+}
+
+func TestJSONConverterWithoutCommitTime(t *testing.T) {
+	msg := testRowMessage(decoderbufs.Op_INSERT)
+	msg.CommitTime = nil
+
+	change := NewJSONConverter(JSONConverterOptions{}).Change(msg, &Catalog{ValuesMap: ValuesMap{}})
+	if change.CommitTime != nil {
+		t.Fatalf("Expected commit_time to be null. Got: %v", *change.CommitTime)
+	}
+}
@@ -1,7 +1,16 @@
 package llsr
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidLogPos = errors.New("llsr: Invalid log position")
 )
 
 //LogPos represents position in PostgreSQL binlog
@@ -14,9 +23,115 @@ func (v LogPos) String() string {
 	return fmt.Sprintf("%X/%X", high, low)
 }
 
-//Converts textual representation (e.g. 17/A4C41EC0) to LogPos
+//Converts textual representation (e.g. 17/A4C41EC0) to LogPos. It returns 0 for invalid input.
+//
+//Deprecated: use ParseLogPos, which reports invalid input.
 func StrToLogPos(str string) LogPos {
-	var high, low uint32
-	fmt.Sscanf(str, "%X/%X", &high, &low)
-	return LogPos((int64(high) << 32) | int64(low))
+	pos, _ := ParseLogPos(str)
+	return pos
+}
+
+//ParseLogPos converts textual representation (e.g. 17/A4C41EC0) to LogPos. Both parts must be hexadecimal numbers of at most 8 digits.
+func ParseLogPos(str string) (LogPos, error) {
+	parts := strings.Split(str, "/")
+	if len(parts) != 2 {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidLogPos, str)
+	}
+
+	var halves [2]uint64
+	for i, part := range parts {
+		if len(part) == 0 || len(part) > 8 {
+			return 0, fmt.Errorf("%w: %q", ErrInvalidLogPos, str)
+		}
+		half, err := strconv.ParseUint(part, 16, 32)
+		if err != nil {
+			return 0, fmt.Errorf("%w: %q", ErrInvalidLogPos, str)
+		}
+		halves[i] = half
+	}
+
+	return LogPos(halves[0]<<32 | halves[1]), nil
+}
+
+//Diff returns number of bytes from other to v, like pg_wal_lsn_diff(v, other). It is negative when other is after v.
+func (v LogPos) Diff(other LogPos) int64 {
+	return int64(v - other)
+}
+
+//Add returns position moved by given number of bytes.
+func (v LogPos) Add(bytes int64) LogPos {
+	return v + LogPos(bytes)
+}
+
+//Compare returns -1 if v is before other, 1 if it is after other and 0 if they are equal.
+func (v LogPos) Compare(other LogPos) int {
+	switch {
+	case v < other:
+		return -1
+	case v > other:
+		return 1
+	}
+	return 0
+}
+
+//Before reports whether v is before other.
+func (v LogPos) Before(other LogPos) bool {
+	return v < other
+}
+
+//After reports whether v is after other.
+func (v LogPos) After(other LogPos) bool {
+	return v > other
+}
+
+//MarshalText implements encoding.TextMarshaler interface.
+func (v LogPos) MarshalText() ([]byte, error) {
+	return []byte(v.String()), nil
+}
+
+//UnmarshalText implements encoding.TextUnmarshaler interface.
+func (v *LogPos) UnmarshalText(text []byte) error {
+	pos, err := ParseLogPos(string(text))
+	if err != nil {
+		return err
+	}
+	*v = pos
+	return nil
+}
+
+//MarshalJSON implements json.Marshaler interface. Position is encoded as string, e.g. "17/A4C41EC0".
+func (v LogPos) MarshalJSON() ([]byte, error) {
+	return json.Marshal(v.String())
+}
+
+//UnmarshalJSON implements json.Unmarshaler interface. null leaves position unchanged.
+func (v *LogPos) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidLogPos, data)
+	}
+	return v.UnmarshalText([]byte(str))
+}
+
+//Scan implements sql.Scanner interface, so pg_lsn and text columns can be scanned into LogPos. NULL is scanned as 0.
+func (v *LogPos) Scan(src interface{}) error {
+	switch value := src.(type) {
+	case nil:
+		*v = 0
+		return nil
+	case string:
+		return v.UnmarshalText([]byte(value))
+	case []byte:
+		return v.UnmarshalText(value)
+	}
+	return fmt.Errorf("%w: cannot scan %T", ErrInvalidLogPos, src)
+}
+
+//Value implements driver.Valuer interface. Position is passed in its textual form accepted by pg_lsn.
+func (v LogPos) Value() (driver.Value, error) {
+	return v.String(), nil
 }
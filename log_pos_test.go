@@ -1,6 +1,8 @@
 package llsr
 
 import (
+	"encoding/json"
+	"errors"
 	"testing"
 )
 
@@ -21,3 +23,113 @@ func TestXLogPosConversion(t *testing.T) {
 		}
 	}
 }
+
+func TestParseLogPos(t *testing.T) {
+	for text, expected := range map[string]LogPos{
+		"0/0":               0,
+		"16/B374D848":       0x16B374D848,
+		"16/b374d848":       0x16B374D848,
+		"FFFFFFFF/FFFFFFFF": LogPos(^uint64(0)),
+	} {
+		pos, err := ParseLogPos(text)
+		if err != nil {
+			t.Fatalf("Unexpected error for %q: %v", text, err)
+		}
+		if pos != expected {
+			t.Fatalf("Expected %q to be %d, got %d", text, expected, pos)
+		}
+	}
+
+	for _, text := range []string{"", "foo", "16", "16/", "/16", "16/B374D848/0", "16/XYZ", "1FFFFFFFF/0", "-1/0", "+1/0", " 1/0"} {
+		if pos, err := ParseLogPos(text); !errors.Is(err, ErrInvalidLogPos) {
+			t.Fatalf("Expected %q to be rejected, got %v, %v", text, pos, err)
+		}
+		if pos := StrToLogPos(text); pos != 0 {
+			t.Fatalf("Expected StrToLogPos(%q) to be 0, got %v", text, pos)
+		}
+	}
+}
+
+func TestLogPosArithmetic(t *testing.T) {
+	a := LogPos(0x16B374D848)
+	b := LogPos(0x16B374D000)
+
+	if diff := a.Diff(b); diff != 0x848 {
+		t.Fatalf("Expected diff 0x848, got %d", diff)
+	}
+	if diff := b.Diff(a); diff != -0x848 {
+		t.Fatalf("Expected diff -0x848, got %d", diff)
+	}
+	if pos := b.Add(0x848); pos != a {
+		t.Fatalf("Expected %v, got %v", a, pos)
+	}
+	if pos := a.Add(-0x848); pos != b {
+		t.Fatalf("Expected %v, got %v", b, pos)
+	}
+
+	if a.Compare(b) != 1 || b.Compare(a) != -1 || a.Compare(a) != 0 {
+		t.Fatal("Unexpected Compare result")
+	}
+	if !b.Before(a) || a.Before(b) || !a.After(b) || b.After(a) || a.Before(a) || a.After(a) {
+		t.Fatal("Unexpected Before/After result")
+	}
+}
+
+func TestLogPosEncoding(t *testing.T) {
+	type config struct {
+		Position LogPos  `json:"position"`
+		Optional *LogPos `json:"optional"`
+	}
+
+	data, err := json.Marshal(&config{Position: 0x16B374D848})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"position":"16/B374D848","optional":null}` {
+		t.Fatalf("Unexpected JSON %s", data)
+	}
+
+	var decoded config
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Position != 0x16B374D848 || decoded.Optional != nil {
+		t.Fatalf("Unexpected decoded value %+v", decoded)
+	}
+
+	if err := json.Unmarshal([]byte(`{"position":"foo"}`), &decoded); !errors.Is(err, ErrInvalidLogPos) {
+		t.Fatalf("Expected ErrInvalidLogPos, got %v", err)
+	}
+	if err := json.Unmarshal([]byte(`{"position":42}`), &decoded); !errors.Is(err, ErrInvalidLogPos) {
+		t.Fatalf("Expected ErrInvalidLogPos, got %v", err)
+	}
+
+	text, err := LogPos(0x16B374D848).MarshalText()
+	if err != nil || string(text) != "16/B374D848" {
+		t.Fatalf("Unexpected text %s, %v", text, err)
+	}
+}
+
+func TestLogPosSQL(t *testing.T) {
+	var pos LogPos
+	for src, expected := range map[interface{}]LogPos{"16/B374D848": 0x16B374D848, nil: 0} {
+		if err := pos.Scan(src); err != nil {
+			t.Fatal(err)
+		}
+		if pos != expected {
+			t.Fatalf("Expected %v, got %v", expected, pos)
+		}
+	}
+
+	if err := pos.Scan([]byte("0/1")); err != nil || pos != 1 {
+		t.Fatalf("Expected 0/1, got %v, %v", pos, err)
+	}
+	if err := pos.Scan(int64(1)); !errors.Is(err, ErrInvalidLogPos) {
+		t.Fatalf("Expected ErrInvalidLogPos, got %v", err)
+	}
+
+	value, err := LogPos(0x16B374D848).Value()
+	if err != nil || value != "16/B374D848" {
+		t.Fatalf("Unexpected value %v, %v", value, err)
+	}
+}
@@ -0,0 +1,67 @@
+package llsr
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Metrics measures backpressure between stages of Client and Stream: pipe reader, protobuf decoder,
+// converter and consumers of Updates and Events. Set it in ClientConfig.Metrics and read it with Snapshot.
+// It is safe for concurrent use. Nil Metrics records nothing.
+type Metrics struct {
+	// Accessed atomically; kept first to be 64-bit aligned.
+	blockedTime   [stageCount]int64
+	droppedEvents int64
+}
+
+// Pipeline stages which may wait for the next one.
+type stage int
+
+const (
+	stageReader stage = iota
+	stageDecoder
+	stageUpdates
+	stageEvents
+	stageCount
+)
+
+// MetricsSnapshot is a copy of Metrics values at some point in time.
+type MetricsSnapshot struct {
+	// ReaderBlocked is the time pg_recvlogical output reader waited for the decoder.
+	ReaderBlocked time.Duration
+	// DecoderBlocked is the time the decoder waited for Client to take decoded messages.
+	DecoderBlocked time.Duration
+	// UpdatesBlocked is the time Client waited for Updates to be read.
+	UpdatesBlocked time.Duration
+	// EventsBlocked is the time Client waited for Events to be read.
+	EventsBlocked time.Duration
+	// DroppedEvents is the number of events dropped because Events was full, see EventsDropOldest and EventReconnect.
+	DroppedEvents uint64
+}
+
+// Snapshot returns current values.
+func (m *Metrics) Snapshot() MetricsSnapshot {
+	if m == nil {
+		return MetricsSnapshot{}
+	}
+	return MetricsSnapshot{
+		ReaderBlocked:  time.Duration(atomic.LoadInt64(&m.blockedTime[stageReader])),
+		DecoderBlocked: time.Duration(atomic.LoadInt64(&m.blockedTime[stageDecoder])),
+		UpdatesBlocked: time.Duration(atomic.LoadInt64(&m.blockedTime[stageUpdates])),
+		EventsBlocked:  time.Duration(atomic.LoadInt64(&m.blockedTime[stageEvents])),
+		DroppedEvents:  uint64(atomic.LoadInt64(&m.droppedEvents)),
+	}
+}
+
+// blocked adds time elapsed since start to time given stage spent blocked.
+func (m *Metrics) blocked(s stage, start time.Time) {
+	if m != nil {
+		atomic.AddInt64(&m.blockedTime[s], int64(time.Since(start)))
+	}
+}
+
+func (m *Metrics) dropEvent() {
+	if m != nil {
+		atomic.AddInt64(&m.droppedEvents, 1)
+	}
+}
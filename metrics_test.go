@@ -0,0 +1,105 @@
+package llsr
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNilMetrics(t *testing.T) {
+	var metrics *Metrics
+	metrics.blocked(stageUpdates, time.Now())
+	metrics.dropEvent()
+
+	if snapshot := metrics.Snapshot(); snapshot != (MetricsSnapshot{}) {
+		t.Fatalf("Expected empty snapshot, got %+v", snapshot)
+	}
+}
+
+func TestMetricsDroppedEvents(t *testing.T) {
+	config := NewClientConfig(nil, nil, "llsr_test_slot")
+	config.CheckedConverter = rejectingConverter{}
+	config.EventsBuffer = 2
+	config.EventPolicy = EventsDropOldest
+	config.Metrics = &Metrics{}
+	c := testReplayClient(t, config, NewReplayConfig(), 1, 2, 3, 4, 5, 6, 7, 8, 9, 10)
+	defer c.Close()
+
+	select {
+	case <-c.Updates():
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timeout")
+	}
+
+	received := 0
+	for event := range c.Events() {
+		received++
+		if event.Type == EventEndOfStream {
+			break
+		}
+	}
+
+	dropped := config.Metrics.Snapshot().DroppedEvents
+	if dropped < 8 || dropped+uint64(received) != 11 {
+		t.Fatalf("Expected 11 events to be received or dropped, got %d received and %d dropped", received, dropped)
+	}
+}
+
+func TestMetricsUpdatesBlocked(t *testing.T) {
+	config := NewClientConfig(nil, &DummyConverter{}, "llsr_test_slot")
+	config.Metrics = &Metrics{}
+	c := testReplayClient(t, config, NewReplayConfig(), 1)
+	defer c.Close()
+
+	time.Sleep(50 * time.Millisecond)
+	expectClientUpdate(t, c, "INSERT users")
+	c.Close()
+
+	if blocked := config.Metrics.Snapshot().UpdatesBlocked; blocked < 40*time.Millisecond {
+		t.Fatalf("Expected delivery to be blocked until update was read, got %v", blocked)
+	}
+}
+
+func TestUpdatesBuffer(t *testing.T) {
+	config := NewClientConfig(nil, &DummyConverter{}, "llsr_test_slot")
+	config.UpdatesBuffer = 3
+	c := testReplayClient(t, config, NewReplayConfig(), 1, 2, 3)
+	defer c.Close()
+
+	timeout := time.After(5 * time.Second)
+	for len(c.Updates()) < 3 {
+		select {
+		case <-timeout:
+			t.Fatalf("Expected 3 buffered updates, got %d", len(c.Updates()))
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	config.UpdatesBuffer = -1
+	if _, err := NewClientWithConfig(config); err != ErrInvalidBufferSize {
+		t.Fatalf("Expected ErrInvalidBufferSize, got %v", err)
+	}
+}
+
+func TestMetricsDroppedDeadLetters(t *testing.T) {
+	config := NewClientConfig(nil, nil, "llsr_test_slot")
+	config.CheckedConverter = rejectingConverter{}
+	config.EventsBuffer = 2
+	config.Metrics = &Metrics{}
+	c := testReplayClient(t, config, NewReplayConfig(), 1, 2, 3, 4, 5, 6, 7, 8, 9, 10)
+	defer c.Close()
+
+	select {
+	case <-c.Updates():
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timeout")
+	}
+
+	if dropped := config.Metrics.Snapshot().DroppedEvents; dropped != 8 {
+		t.Fatalf("Expected 8 dead letters to be dropped, got %d", dropped)
+	}
+	for i := 0; i < 2; i++ {
+		if event := <-c.Events(); event.Type != EventDeadLetter {
+			t.Fatalf("Expected EventDeadLetter, got %v", event)
+		}
+	}
+}
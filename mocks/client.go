@@ -1,6 +1,8 @@
 package mocks
 
 import (
+	"sync"
+
 	"github.com/liquidm/llsr"
 	"github.com/liquidm/llsr/decoderbufs"
 )
@@ -13,8 +15,17 @@ type Client struct {
 	converter    llsr.Converter
 	expectations chan interface{}
 	closeChan    chan int
+	closeOnce    sync.Once
 	updates      chan interface{}
 	events       chan *llsr.Event
+
+	resnapshotLock         sync.Mutex
+	resnapshotExpectations []resnapshotExpectation
+}
+
+type resnapshotExpectation struct {
+	table string
+	err   error
 }
 
 // NewClient returns a new mock Client instance. The t argument should
@@ -45,6 +56,33 @@ func (c *Client) Events() <-chan *llsr.Event {
 	return c.events
 }
 
+// Resnapshot implements Resnapshot method from llsr.Client interface.
+// It returns error defined with ExpectResnapshot and reports unexpected calls.
+func (c *Client) Resnapshot(table string) error {
+	c.resnapshotLock.Lock()
+	defer c.resnapshotLock.Unlock()
+
+	if len(c.resnapshotExpectations) == 0 {
+		c.t.Errorf("Unexpected Resnapshot of %s table", table)
+		return nil
+	}
+
+	expectation := c.resnapshotExpectations[0]
+	c.resnapshotExpectations = c.resnapshotExpectations[1:]
+	if expectation.table != table {
+		c.t.Errorf("Expected Resnapshot of %s table, got %s", expectation.table, table)
+	}
+	return expectation.err
+}
+
+// ExpectResnapshot allows you to expect Resnapshot call for given table. Call returns err.
+func (c *Client) ExpectResnapshot(table string, err error) {
+	c.resnapshotLock.Lock()
+	defer c.resnapshotLock.Unlock()
+
+	c.resnapshotExpectations = append(c.resnapshotExpectations, resnapshotExpectation{table: table, err: err})
+}
+
 // ExpectYieldMessage allows you to create message expectations
 func (c *Client) ExpectYieldMessage(msg *decoderbufs.RowMessage) {
 	c.expectations <- msg
@@ -71,13 +109,25 @@ func (c *Client) ExpectBackendInvalidExitStatusEvent(err error) {
 }
 
 // Closes implements Close method from llsr.Client interface.
-// It closes client and makes sure every message were consumed.
+// It closes client, Updates and Events and makes sure every message were consumed. It may be called more than once.
 func (c *Client) Close() {
+	c.closeOnce.Do(c.close)
+}
+
+func (c *Client) close() {
 	close(c.expectations)
 	<-c.closeChan
 	if len(c.updates) > 0 || len(c.events) > 0 {
 		c.t.Errorf("Not all messages were consumed")
 	}
+	close(c.updates)
+	close(c.events)
+
+	c.resnapshotLock.Lock()
+	defer c.resnapshotLock.Unlock()
+	if len(c.resnapshotExpectations) > 0 {
+		c.t.Errorf("Not all expected Resnapshot calls were made")
+	}
 }
 
 func (c *Client) handleExpectations() {
@@ -47,7 +47,7 @@ func TestClientHandlesUpdateExpectations(t *testing.T) {
 	event := <-client.Events()
 
 	if event.Type != llsr.EventReconnect {
-		t.Errorf("Expected to receive llsr.EventReconnect got %s instead", event.Type)
+		t.Errorf("Expected to receive llsr.EventReconnect got %v instead", event.Type)
 	}
 }
 
@@ -100,3 +100,53 @@ func TestExpect(t *testing.T) {
 		t.Error("Expected to receive llsr.EventBackendInvalidExitStatus event")
 	}
 }
+
+func TestExpectResnapshot(t *testing.T) {
+	client := NewClient(t, &DummyConverter{})
+	expectedErr := errors.New("resnapshot failed")
+	client.ExpectResnapshot("users", expectedErr)
+
+	if err := client.Resnapshot("users"); err != expectedErr {
+		t.Errorf("Expected Resnapshot to return %v, got %v", expectedErr, err)
+	}
+
+	client.Close()
+}
+
+func TestUnexpectedResnapshot(t *testing.T) {
+	trm := newTestReporterMock()
+	client := NewClient(trm, &DummyConverter{})
+
+	client.Resnapshot("users")
+
+	if len(trm.errors) == 0 {
+		t.Errorf("Expected to return error on unexpected Resnapshot call")
+	}
+}
+
+func TestClientMeetsNotAllResnapshotsCalledError(t *testing.T) {
+	trm := newTestReporterMock()
+	client := NewClient(trm, &DummyConverter{})
+
+	client.ExpectResnapshot("users", nil)
+
+	client.Close()
+
+	if len(trm.errors) == 0 {
+		t.Errorf("Expected to return error if not all expected Resnapshot calls were made")
+	}
+}
+
+func TestClientCloseClosesChannels(t *testing.T) {
+	client := NewClient(t, &DummyConverter{})
+
+	client.Close()
+	client.Close()
+
+	if _, ok := <-client.Updates(); ok {
+		t.Error("Expected Updates to be closed")
+	}
+	if _, ok := <-client.Events(); ok {
+		t.Error("Expected Events to be closed")
+	}
+}
@@ -0,0 +1,171 @@
+package llsr
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/liquidm/llsr/decoderbufs"
+)
+
+// Configuration of Peek.
+type PeekConfig struct {
+	// StartPosition makes Peek skip messages with lower log position.
+	StartPosition LogPos
+	// Limit is the number of changes after which Postgres stops decoding. It always finishes the current
+	// transaction, so more changes may be returned. Zero reads all pending changes.
+	Limit int
+	// UpTo makes Postgres stop decoding at transactions committed after given position. Zero reads all pending changes.
+	UpTo LogPos
+}
+
+// Creates new PeekConfig reading all pending changes.
+func NewPeekConfig() *PeekConfig {
+	return &PeekConfig{}
+}
+
+// Peek is a Source reading pending changes of a slot with pg_logical_slot_peek_binary_changes.
+// Unlike Stream it does not consume them, so confirmed_flush_lsn of the slot does not move and
+// the same changes are read again later. The slot must not be in use by another connection.
+//
+// Peek finishes with io.EOF once all changes were read. Query and decode errors wrap ErrSourceFailed.
+type Peek struct {
+	dbConfig *DatabaseConfig
+	slot     string
+	config   PeekConfig
+
+	running   bool
+	closeOnce sync.Once
+	closeChan chan struct{}
+
+	msgChan   chan *decoderbufs.RowMessage
+	errEvents chan interface{}
+	finished  chan error
+}
+
+// Creates new Peek of changes pending in slot.
+func NewPeek(dbConfig *DatabaseConfig, slot string, config *PeekConfig) *Peek {
+	return &Peek{
+		dbConfig:  dbConfig,
+		slot:      slot,
+		config:    *config,
+		closeChan: make(chan struct{}),
+		msgChan:   make(chan *decoderbufs.RowMessage),
+		errEvents: make(chan interface{}),
+		finished:  make(chan error, 1),
+	}
+}
+
+// PeekSource returns SourceFactory peeking changes pending in slot. Client using it stops once all of them
+// were delivered.
+func PeekSource(dbConfig *DatabaseConfig, slot string, config *PeekConfig) SourceFactory {
+	return func(startPosition LogPos) (Source, error) {
+		peekConfig := *config
+		if startPosition > peekConfig.StartPosition {
+			peekConfig.StartPosition = startPosition
+		}
+		return NewPeek(dbConfig, slot, &peekConfig), nil
+	}
+}
+
+// Start implements Source interface.
+func (p *Peek) Start() error {
+	if p.running {
+		return ErrStreamAlreadyRunning
+	}
+	p.running = true
+
+	go p.run()
+
+	return nil
+}
+
+// Close implements Source interface.
+func (p *Peek) Close() error {
+	p.closeOnce.Do(func() {
+		close(p.closeChan)
+	})
+	return nil
+}
+
+// Data implements Source interface. It is closed once all changes were read.
+func (p *Peek) Data() <-chan *decoderbufs.RowMessage {
+	return p.msgChan
+}
+
+// ErrOut implements Source interface. Peek produces no diagnostic messages.
+func (p *Peek) ErrOut() <-chan interface{} {
+	return p.errEvents
+}
+
+// Finished implements Source interface.
+func (p *Peek) Finished() <-chan error {
+	return p.finished
+}
+
+func (p *Peek) run() {
+	err := p.peek()
+	switch err {
+	case io.EOF:
+		close(p.msgChan)
+	case nil:
+	default:
+		err = fmt.Errorf("%w: %v", ErrSourceFailed, err)
+	}
+	p.finished <- err
+}
+
+// peek sends pending changes. It returns io.EOF when all of them were sent and nil when closed.
+func (p *Peek) peek() error {
+	db := p.dbConfig.open()
+	defer db.Close()
+
+	var upTo, limit interface{}
+	if p.config.UpTo > 0 {
+		upTo = p.config.UpTo
+	}
+	if p.config.Limit > 0 {
+		limit = p.config.Limit
+	}
+	rows, err := db.Query("SELECT data FROM pg_logical_slot_peek_binary_changes($1, $2, $3)", p.slot, upTo, limit)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return err
+		}
+
+		msg := &decoderbufs.RowMessage{}
+		if err := proto.Unmarshal(peekPayload(data), msg); err != nil {
+			return err
+		}
+		if LogPos(msg.GetLogPosition()) < p.config.StartPosition {
+			continue
+		}
+
+		select {
+		case p.msgChan <- msg:
+		case <-p.closeChan:
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	return io.EOF
+}
+
+// peekPayload strips length prefix decoderbufs writes in front of every message.
+func peekPayload(data []byte) []byte {
+	if len(data) >= 8 && binary.BigEndian.Uint64(data) == uint64(len(data)-8) {
+		return data[8:]
+	}
+	return data
+}
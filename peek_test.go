@@ -0,0 +1,82 @@
+package llsr
+
+import (
+	"bytes"
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestPeekPayload(t *testing.T) {
+	prefixed := []byte{0, 0, 0, 0, 0, 0, 0, 3, 'f', 'o', 'o'}
+	if payload := peekPayload(prefixed); !bytes.Equal(payload, []byte("foo")) {
+		t.Fatalf("Expected prefix to be stripped, got %q", payload)
+	}
+
+	raw := []byte("foobarbazqux")
+	if payload := peekPayload(raw); !bytes.Equal(payload, raw) {
+		t.Fatalf("Expected data without prefix to be unchanged, got %q", payload)
+	}
+}
+
+func TestPeekDoesNotConsumeSlot(t *testing.T) {
+	withTestConnection(t, func(t *testing.T, db *sql.DB) {
+		if _, err := db.Exec("INSERT INTO llsr_test_table (id, txt) VALUES (1, 'foo'), (2, 'bar')"); err != nil {
+			t.Fatal(err)
+		}
+
+		for i := 0; i < 2; i++ {
+			config := NewClientConfig(testConfig(), &DummyConverter{}, "llsr_test_slot")
+			config.Source = PeekSource(config.Database, "llsr_test_slot", NewPeekConfig())
+
+			c, err := NewClientWithConfig(config)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			expectClientUpdate(t, c, "INSERT llsr_test_table")
+			expectClientUpdate(t, c, "INSERT llsr_test_table")
+			expectClientEvent(t, c, EventEndOfStream)
+			c.Close()
+		}
+	})
+}
+
+func TestPeekUpTo(t *testing.T) {
+	withTestConnection(t, func(t *testing.T, db *sql.DB) {
+		if _, err := db.Exec("INSERT INTO llsr_test_table (id, txt) VALUES (1, 'foo')"); err != nil {
+			t.Fatal(err)
+		}
+		var upTo LogPos
+		if err := db.QueryRow("SELECT pg_current_wal_lsn()").Scan(&upTo); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Exec("INSERT INTO llsr_test_table (id, txt) VALUES (2, 'bar')"); err != nil {
+			t.Fatal(err)
+		}
+
+		config := NewPeekConfig()
+		config.UpTo = upTo
+		peek := NewPeek(testConfig(), "llsr_test_slot", config)
+		if err := peek.Start(); err != nil {
+			t.Fatal(err)
+		}
+		defer peek.Close()
+
+		count := 0
+		for {
+			select {
+			case _, ok := <-peek.Data():
+				if !ok {
+					if count != 1 {
+						t.Fatalf("Expected 1 change up to %s, got %d", upTo, count)
+					}
+					return
+				}
+				count++
+			case <-time.After(5 * time.Second):
+				t.Fatal("Timeout")
+			}
+		}
+	})
+}
@@ -0,0 +1,347 @@
+package llsr
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/liquidm/llsr/decoderbufs"
+)
+
+// Default size after which Recorder starts a new segment.
+const DefaultSegmentSize = 64 << 20
+
+const (
+	segmentExt           = ".llsr"
+	compressedSegmentExt = ".llsr.gz"
+)
+
+var (
+	ErrRecorderClosed = errors.New("llsr: Recorder is closed")
+)
+
+// Configuration of Recorder.
+type RecorderConfig struct {
+	// Dir is the directory segments are written to. It is created when missing.
+	Dir string
+	// SegmentSize is the number of uncompressed bytes after which a new segment is started.
+	SegmentSize int64
+	// SegmentDuration is the time after which a new segment is started. Zero disables time based rotation.
+	SegmentDuration time.Duration
+	// Compress makes Recorder write gzip compressed segments.
+	Compress bool
+
+	// MaxTotalSize makes Recorder remove oldest segments when segments in Dir take more bytes on disk. Zero disables the limit.
+	MaxTotalSize int64
+	// MaxAge makes Recorder remove segments last written longer ago. Zero disables the limit.
+	MaxAge time.Duration
+}
+
+// Creates new RecorderConfig writing to dir with default segment size and no retention limits.
+func NewRecorderConfig(dir string) *RecorderConfig {
+	return &RecorderConfig{
+		Dir:         dir,
+		SegmentSize: DefaultSegmentSize,
+	}
+}
+
+// Segment describes single file written by Recorder.
+type Segment struct {
+	// Path of the segment file.
+	Path string
+	// StartPos is the log position of the first frame in segment.
+	StartPos LogPos
+	// Compressed is true for gzip compressed segments.
+	Compressed bool
+
+	created int64
+}
+
+// Recorder writes raw frames received from pg_recvlogical into segment files, so the stream can be
+// replayed and decode or converter bugs reproduced offline. Frames are stored exactly as received,
+// including frames which cannot be decoded.
+//
+// Segments are named after the log position of their first frame and may be listed with ListSegments.
+// When writing fails Recorder stops recording; the error is available with Err. Recorder may be shared
+// by subsequent streams of a Client and must be closed by its owner after Client is closed.
+type Recorder struct {
+	config RecorderConfig
+
+	lock     sync.Mutex
+	file     *os.File
+	gzip     *gzip.Writer
+	writer   io.Writer
+	size     int64
+	openedAt time.Time
+	lastPos  LogPos
+	err      error
+	closed   bool
+}
+
+// Creates new Recorder. Segments are created lazily with the first recorded frame.
+func NewRecorder(config *RecorderConfig) (*Recorder, error) {
+	if err := os.MkdirAll(config.Dir, 0700); err != nil {
+		return nil, err
+	}
+
+	recorder := &Recorder{config: *config}
+	if recorder.config.SegmentSize <= 0 {
+		recorder.config.SegmentSize = DefaultSegmentSize
+	}
+	return recorder, nil
+}
+
+// Record appends frame payload to current segment, rotating segments and applying retention as needed.
+func (r *Recorder) Record(data []byte) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.closed {
+		return ErrRecorderClosed
+	}
+	if r.err != nil {
+		return r.err
+	}
+
+	if r.writer != nil && r.rotationDue() {
+		if err := r.closeSegment(); err != nil {
+			return r.fail(err)
+		}
+		if err := r.applyRetention(); err != nil {
+			return r.fail(err)
+		}
+	}
+
+	if r.writer == nil {
+		if err := r.openSegment(data); err != nil {
+			return r.fail(err)
+		}
+	}
+
+	if err := writeFrame(r.writer, data); err != nil {
+		return r.fail(err)
+	}
+	r.size += int64(len(data)) + 9
+
+	return nil
+}
+
+// Err returns error which stopped recording.
+func (r *Recorder) Err() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.err
+}
+
+// Close finishes current segment.
+func (r *Recorder) Close() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+
+	if r.writer == nil {
+		return nil
+	}
+	return r.closeSegment()
+}
+
+func (r *Recorder) fail(err error) error {
+	r.err = err
+	if r.writer != nil {
+		r.closeSegment()
+	}
+	return err
+}
+
+func (r *Recorder) rotationDue() bool {
+	if r.size >= r.config.SegmentSize {
+		return true
+	}
+	return r.config.SegmentDuration > 0 && time.Since(r.openedAt) >= r.config.SegmentDuration
+}
+
+// openSegment starts new segment named after log position of data. Undecodable frames get position of
+// the last decoded one.
+func (r *Recorder) openSegment(data []byte) error {
+	msg := &decoderbufs.RowMessage{}
+	if err := proto.Unmarshal(data, msg); err == nil && msg.GetLogPosition() > 0 {
+		r.lastPos = LogPos(msg.GetLogPosition())
+	}
+
+	now := time.Now()
+	ext := segmentExt
+	if r.config.Compress {
+		ext = compressedSegmentExt
+	}
+	path := filepath.Join(r.config.Dir, fmt.Sprintf("%016X-%d%s", uint64(r.lastPos), now.UnixNano(), ext))
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+
+	r.file = file
+	r.writer = file
+	if r.config.Compress {
+		r.gzip = gzip.NewWriter(file)
+		r.writer = r.gzip
+	}
+	r.size = 0
+	r.openedAt = now
+
+	return nil
+}
+
+func (r *Recorder) closeSegment() error {
+	var err error
+	if r.gzip != nil {
+		err = r.gzip.Close()
+	}
+	if closeErr := r.file.Close(); err == nil {
+		err = closeErr
+	}
+
+	r.file = nil
+	r.gzip = nil
+	r.writer = nil
+
+	return err
+}
+
+// applyRetention removes oldest segments exceeding MaxTotalSize or MaxAge. It is called when no segment is open.
+func (r *Recorder) applyRetention() error {
+	if r.config.MaxTotalSize <= 0 && r.config.MaxAge <= 0 {
+		return nil
+	}
+
+	segments, err := ListSegments(r.config.Dir)
+	if err != nil {
+		return err
+	}
+
+	infos := make([]os.FileInfo, len(segments))
+	var total int64
+	for i, segment := range segments {
+		if infos[i], err = os.Stat(segment.Path); err != nil {
+			return err
+		}
+		total += infos[i].Size()
+	}
+
+	for i, segment := range segments {
+		expired := r.config.MaxAge > 0 && time.Since(infos[i].ModTime()) > r.config.MaxAge
+		oversized := r.config.MaxTotalSize > 0 && total > r.config.MaxTotalSize
+		if !expired && !oversized {
+			break
+		}
+		if err := os.Remove(segment.Path); err != nil {
+			return err
+		}
+		total -= infos[i].Size()
+	}
+
+	return nil
+}
+
+// ListSegments returns segments written by Recorder to dir ordered by their start position.
+func ListSegments(dir string) ([]*Segment, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var segments []*Segment
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if segment, ok := parseSegmentName(entry.Name()); ok {
+			segment.Path = filepath.Join(dir, entry.Name())
+			segments = append(segments, segment)
+		}
+	}
+
+	sort.Slice(segments, func(i, j int) bool {
+		if segments[i].StartPos != segments[j].StartPos {
+			return segments[i].StartPos < segments[j].StartPos
+		}
+		return segments[i].created < segments[j].created
+	})
+
+	return segments, nil
+}
+
+func parseSegmentName(name string) (*Segment, bool) {
+	segment := &Segment{}
+	switch {
+	case strings.HasSuffix(name, compressedSegmentExt):
+		segment.Compressed = true
+		name = strings.TrimSuffix(name, compressedSegmentExt)
+	case strings.HasSuffix(name, segmentExt):
+		name = strings.TrimSuffix(name, segmentExt)
+	default:
+		return nil, false
+	}
+
+	parts := strings.SplitN(name, "-", 2)
+	if len(parts) != 2 {
+		return nil, false
+	}
+	pos, err := strconv.ParseUint(parts[0], 16, 64)
+	if err != nil {
+		return nil, false
+	}
+	created, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return nil, false
+	}
+
+	segment.StartPos = LogPos(pos)
+	segment.created = created
+	return segment, true
+}
+
+// Open returns reader of segment's frames, decompressing it when needed.
+func (s *Segment) Open() (io.ReadCloser, error) {
+	file, err := os.Open(s.Path)
+	if err != nil {
+		return nil, err
+	}
+	if !s.Compressed {
+		return file, nil
+	}
+
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &gzipSegmentReader{Reader: reader, file: file}, nil
+}
+
+type gzipSegmentReader struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (r *gzipSegmentReader) Close() error {
+	err := r.Reader.Close()
+	if closeErr := r.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
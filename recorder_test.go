@@ -0,0 +1,170 @@
+package llsr
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/liquidm/llsr/decoderbufs"
+)
+
+func testMessage(pos uint64, table string, id int32) *decoderbufs.RowMessage {
+	return &decoderbufs.RowMessage{
+		LogPosition: proto.Uint64(pos),
+		Table:       proto.String(table),
+		Op:          decoderbufs.Op_INSERT.Enum(),
+		NewTuple:    testTuple(id, "foo"),
+	}
+}
+
+func testFrame(t *testing.T, pos uint64) []byte {
+	return testMessageFrame(t, testMessage(pos, "users", int32(pos)))
+}
+
+func testMessageFrame(t *testing.T, msg *decoderbufs.RowMessage) []byte {
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func readSegment(t *testing.T, segment *Segment) []uint64 {
+	reader, err := segment.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	var positions []uint64
+	for {
+		data, err := readFrame(reader)
+		if err != nil {
+			break
+		}
+		msg := &decoderbufs.RowMessage{}
+		if err := proto.Unmarshal(data, msg); err != nil {
+			t.Fatal(err)
+		}
+		positions = append(positions, msg.GetLogPosition())
+	}
+	return positions
+}
+
+func testRecord(t *testing.T, config *RecorderConfig, positions ...uint64) {
+	recorder, err := NewRecorder(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pos := range positions {
+		if err := recorder.Record(testFrame(t, pos)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := recorder.Record(testFrame(t, 100)); err != ErrRecorderClosed {
+		t.Fatalf("Expected ErrRecorderClosed, got %v", err)
+	}
+}
+
+func TestRecorderRotatesSegments(t *testing.T) {
+	for _, compress := range []bool{false, true} {
+		config := NewRecorderConfig(t.TempDir())
+		config.SegmentSize = int64(2 * (len(testFrame(t, 1)) + 9))
+		config.Compress = compress
+
+		testRecord(t, config, 1, 2, 3, 4, 5)
+
+		segments, err := ListSegments(config.Dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(segments) != 3 {
+			t.Fatalf("Expected 3 segments, got %d", len(segments))
+		}
+
+		expected := [][]uint64{{1, 2}, {3, 4}, {5}}
+		for i, segment := range segments {
+			if segment.Compressed != compress {
+				t.Fatalf("Expected compressed %v, got %v", compress, segment.Compressed)
+			}
+			if segment.StartPos != LogPos(expected[i][0]) {
+				t.Fatalf("Expected segment to start at %d, got %d", expected[i][0], segment.StartPos)
+			}
+			positions := readSegment(t, segment)
+			if len(positions) != len(expected[i]) {
+				t.Fatalf("Expected %v, got %v", expected[i], positions)
+			}
+			for j := range positions {
+				if positions[j] != expected[i][j] {
+					t.Fatalf("Expected %v, got %v", expected[i], positions)
+				}
+			}
+		}
+	}
+}
+
+func TestRecorderRetention(t *testing.T) {
+	config := NewRecorderConfig(t.TempDir())
+	frameSize := int64(len(testFrame(t, 1)) + 9)
+	config.SegmentSize = frameSize
+	config.MaxTotalSize = 2 * frameSize
+
+	testRecord(t, config, 1, 2, 3, 4, 5)
+
+	segments, err := ListSegments(config.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(segments) != 3 || segments[0].StartPos != 3 {
+		t.Fatalf("Expected segments starting at 3, 4 and 5, got %d segments", len(segments))
+	}
+}
+
+func TestRecorderRetentionByAge(t *testing.T) {
+	config := NewRecorderConfig(t.TempDir())
+	config.SegmentSize = 1
+	config.MaxAge = time.Hour
+
+	testRecord(t, config, 1, 2)
+
+	segments, err := ListSegments(config.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(segments[0].Path, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	testRecord(t, config, 3, 4)
+
+	segments, err = ListSegments(config.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(segments) != 3 || segments[0].StartPos != 2 {
+		t.Fatalf("Expected segments starting at 2, 3 and 4, got %d segments", len(segments))
+	}
+}
+
+func TestListSegmentsIgnoresOtherFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"README", "0000000000000001-1.llsr", "foo-1.llsr", "0000000000000001.llsr"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	segments, err := ListSegments(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(segments) != 1 || segments[0].StartPos != 1 {
+		t.Fatalf("Expected single segment, got %d", len(segments))
+	}
+}
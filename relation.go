@@ -0,0 +1,193 @@
+package llsr
+
+import (
+	"database/sql"
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/lib/pq"
+	"github.com/liquidm/llsr/decoderbufs"
+)
+
+// Column describes single table column as stored in pg_attribute.
+type Column struct {
+	Name       string
+	Position   int
+	Type       int
+	TypeMod    int
+	NotNull    bool
+	PrimaryKey bool
+}
+
+// Relation describes table which changes are received from replication slot.
+type Relation struct {
+	OID    int
+	Schema string
+	Name   string
+	// QualifiedName is schema qualified table name, quoted when necessary, usable in queries.
+	QualifiedName string
+	// Columns are ordered by their position in table.
+	Columns []*Column
+	// PrimaryKey holds names of primary key columns in index order. It is empty if table has no primary key.
+	PrimaryKey []string
+}
+
+// Column returns column with given name or nil if relation has no such column.
+func (r *Relation) Column(name string) *Column {
+	for _, column := range r.Columns {
+		if column.Name == name {
+			return column
+		}
+	}
+	return nil
+}
+
+// MissingColumns returns names of relation columns which are not present in tuple.
+func (r *Relation) MissingColumns(tuple []*decoderbufs.DatumMessage) []string {
+	present := make(map[string]bool, len(tuple))
+	for _, msg := range tuple {
+		present[msg.GetColumnName()] = true
+	}
+
+	var missing []string
+	for _, column := range r.Columns {
+		if !present[column.Name] {
+			missing = append(missing, column.Name)
+		}
+	}
+	return missing
+}
+
+// matches reports whether every column in tuple exists in relation with the same type.
+func (r *Relation) matches(tuple []*decoderbufs.DatumMessage) bool {
+	for _, msg := range tuple {
+		column := r.Column(msg.GetColumnName())
+		if column == nil || (msg.ColumnType != nil && int64(column.Type) != msg.GetColumnType()) {
+			return false
+		}
+	}
+	return true
+}
+
+// RelationCache lazily loads and caches Relation descriptions. Tables are identified by names
+// as they appear in RowMessage.
+type RelationCache struct {
+	db *sql.DB
+
+	lock      sync.Mutex
+	relations map[string]*Relation
+	// failures holds errors of tables which cannot be resolved, e.g. because they are not on search_path.
+	failures map[string]error
+}
+
+func newRelationCache(db *sql.DB) *RelationCache {
+	return &RelationCache{
+		db:        db,
+		relations: make(map[string]*Relation),
+		failures:  make(map[string]error),
+	}
+}
+
+// Get returns description of given table. It is loaded from database if not cached yet.
+// Tables which cannot be resolved are remembered until they are invalidated, so they are not queried again.
+func (c *RelationCache) Get(table string) (*Relation, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if relation, ok := c.relations[table]; ok {
+		return relation, nil
+	}
+	if err, ok := c.failures[table]; ok {
+		return nil, err
+	}
+
+	if c.db == nil {
+		return nil, ErrNoDatabase
+	}
+
+	relation, err := loadRelation(c.db, table)
+	if err != nil {
+		if unresolvable(err) {
+			c.failures[table] = err
+		}
+		return nil, err
+	}
+
+	c.relations[table] = relation
+	return relation, nil
+}
+
+// Invalidate removes table from cache, so it is reloaded on next Get.
+func (c *RelationCache) Invalidate(table string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	delete(c.relations, table)
+	delete(c.failures, table)
+}
+
+// InvalidateAll removes every table from cache.
+func (c *RelationCache) InvalidateAll() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.relations = make(map[string]*Relation)
+	c.failures = make(map[string]error)
+}
+
+// unresolvable reports whether err means table does not exist or is not accessible, rather than e.g. lost connection.
+func unresolvable(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code.Class() == "42" || pqErr.Code.Class() == "3F"
+	}
+	return err == sql.ErrNoRows
+}
+
+func loadRelation(db *sql.DB, table string) (*Relation, error) {
+	relation := &Relation{}
+
+	err := db.QueryRow(`SELECT c.oid, n.nspname, c.relname, quote_ident(n.nspname) || '.' || quote_ident(c.relname)
+		FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE c.oid = $1::regclass`, table).Scan(&relation.OID, &relation.Schema, &relation.Name, &relation.QualifiedName)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(`SELECT a.attname, a.attnum, a.atttypid, a.atttypmod, a.attnotnull, array_position(i.indkey::int2[], a.attnum)
+		FROM pg_attribute a LEFT JOIN pg_index i ON i.indrelid = a.attrelid AND i.indisprimary
+		WHERE a.attrelid = $1 AND a.attnum > 0 AND NOT a.attisdropped
+		ORDER BY a.attnum`, relation.OID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keyPositions := make(map[string]int64)
+	for rows.Next() {
+		var keyPosition sql.NullInt64
+		column := &Column{}
+
+		if err := rows.Scan(&column.Name, &column.Position, &column.Type, &column.TypeMod, &column.NotNull, &keyPosition); err != nil {
+			return nil, err
+		}
+
+		if keyPosition.Valid {
+			column.PrimaryKey = true
+			keyPositions[column.Name] = keyPosition.Int64
+			relation.PrimaryKey = append(relation.PrimaryKey, column.Name)
+		}
+
+		relation.Columns = append(relation.Columns, column)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(relation.PrimaryKey, func(i, j int) bool {
+		return keyPositions[relation.PrimaryKey[i]] < keyPositions[relation.PrimaryKey[j]]
+	})
+
+	return relation, nil
+}
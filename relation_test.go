@@ -0,0 +1,150 @@
+package llsr
+
+import (
+	"database/sql"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/lib/pq"
+	"github.com/liquidm/llsr/decoderbufs"
+)
+
+func testRelation() *Relation {
+	return &Relation{
+		Schema:        "public",
+		Name:          "llsr_test_table",
+		QualifiedName: "public.llsr_test_table",
+		Columns: []*Column{
+			{Name: "id", Position: 1, Type: 23, TypeMod: -1, NotNull: true, PrimaryKey: true},
+			{Name: "txt", Position: 2, Type: 25, TypeMod: -1, NotNull: true},
+		},
+		PrimaryKey: []string{"id"},
+	}
+}
+
+func testDatum(columnName string, columnType int64) *decoderbufs.DatumMessage {
+	return &decoderbufs.DatumMessage{ColumnName: proto.String(columnName), ColumnType: proto.Int64(columnType)}
+}
+
+func TestRelationColumn(t *testing.T) {
+	relation := testRelation()
+
+	if column := relation.Column("txt"); column == nil || column.Position != 2 {
+		t.Fatalf("Expected Column() to return txt column. Got: %+v", column)
+	}
+
+	if relation.Column("missing") != nil {
+		t.Fatal("Expected Column() to return nil for unknown column")
+	}
+}
+
+func TestRelationMissingColumns(t *testing.T) {
+	relation := testRelation()
+
+	missing := relation.MissingColumns([]*decoderbufs.DatumMessage{testDatum("id", 23)})
+	if !reflect.DeepEqual(missing, []string{"txt"}) {
+		t.Fatalf("Expected MissingColumns() to return txt. Got: %v", missing)
+	}
+
+	missing = relation.MissingColumns([]*decoderbufs.DatumMessage{testDatum("id", 23), testDatum("txt", 25)})
+	if len(missing) != 0 {
+		t.Fatalf("Expected MissingColumns() to return nothing. Got: %v", missing)
+	}
+}
+
+func TestRelationMatches(t *testing.T) {
+	relation := testRelation()
+
+	if !relation.matches([]*decoderbufs.DatumMessage{testDatum("id", 23), testDatum("txt", 25)}) {
+		t.Fatal("Expected relation to match tuple with the same columns")
+	}
+
+	if relation.matches([]*decoderbufs.DatumMessage{testDatum("id", 23), testDatum("other", 25)}) {
+		t.Fatal("Expected relation not to match tuple with unknown column")
+	}
+
+	if relation.matches([]*decoderbufs.DatumMessage{testDatum("id", 20)}) {
+		t.Fatal("Expected relation not to match tuple with different column type")
+	}
+}
+
+func TestRelationCache(t *testing.T) {
+	withTestConnection(t, func(t *testing.T, db *sql.DB) {
+		cache := newRelationCache(db)
+
+		relation, err := cache.Get("public.llsr_test_table")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if relation.Schema != "public" || relation.Name != "llsr_test_table" || relation.QualifiedName != "public.llsr_test_table" {
+			t.Fatalf("Expected public.llsr_test_table relation. Got: %s.%s (%s)", relation.Schema, relation.Name, relation.QualifiedName)
+		}
+
+		if !reflect.DeepEqual(relation.Columns, testRelation().Columns) {
+			t.Fatalf("Expected columns to be loaded from pg_attribute. Got: %+v, %+v", relation.Columns[0], relation.Columns[1])
+		}
+
+		if !reflect.DeepEqual(relation.PrimaryKey, []string{"id"}) {
+			t.Fatalf("Expected primary key to be loaded from pg_index. Got: %v", relation.PrimaryKey)
+		}
+
+		_, err = db.Exec("ALTER TABLE llsr_test_table ADD COLUMN extra int")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		cached, _ := cache.Get("public.llsr_test_table")
+		if cached != relation {
+			t.Fatal("Expected Get() to return cached relation")
+		}
+
+		cache.Invalidate("public.llsr_test_table")
+
+		relation, err = cache.Get("public.llsr_test_table")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if relation.Column("extra") == nil {
+			t.Fatal("Expected Get() to reload relation after Invalidate()")
+		}
+	})
+}
+
+func TestRelationCacheRemembersFailures(t *testing.T) {
+	withTestConnection(t, func(t *testing.T, db *sql.DB) {
+		cache := newRelationCache(db)
+
+		if _, err := cache.Get("llsr_test_missing"); err == nil {
+			t.Fatal("Expected Get() to fail for missing table")
+		}
+
+		_, err := db.Exec("CREATE TABLE llsr_test_missing (id int primary key)")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Exec("DROP TABLE llsr_test_missing")
+
+		if _, err := cache.Get("llsr_test_missing"); err == nil {
+			t.Fatal("Expected Get() to return cached failure")
+		}
+
+		cache.Invalidate("llsr_test_missing")
+
+		if _, err := cache.Get("llsr_test_missing"); err != nil {
+			t.Fatalf("Expected Get() to load relation after Invalidate(). Got: %v", err)
+		}
+	})
+}
+
+func TestUnresolvable(t *testing.T) {
+	if !unresolvable(&pq.Error{Code: "42P01"}) || !unresolvable(sql.ErrNoRows) {
+		t.Fatal("Expected missing table to be unresolvable")
+	}
+	if unresolvable(&pq.Error{Code: "08006"}) || unresolvable(errors.New("connection reset")) {
+		t.Fatal("Expected connection failures not to be cached")
+	}
+}
@@ -0,0 +1,336 @@
+package llsr
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/liquidm/llsr/decoderbufs"
+)
+
+// Default time Replay waits for new data when following.
+const DefaultReplayPollInterval = 200 * time.Millisecond
+
+// Replay speeds. Other positive values scale original pacing, e.g. 2 replays twice as fast.
+const (
+	// Messages are emitted as fast as they are consumed.
+	ReplayAsFastAsPossible = 0
+	// Messages are emitted with the same delays between their commit times as in original stream.
+	ReplayRealTime = 1
+)
+
+var errReplayClosed = errors.New("llsr: Replay closed")
+
+// Configuration of Replay.
+type ReplayConfig struct {
+	// StartPosition makes Replay skip messages with lower log position.
+	StartPosition LogPos
+	// Speed of replay, see ReplayAsFastAsPossible and ReplayRealTime.
+	Speed float64
+	// Follow makes Replay wait for more data at the end of input instead of finishing with io.EOF.
+	// Following segments requires them to be uncompressed.
+	Follow bool
+	// PollInterval is the time Replay waits before checking for more data when following.
+	PollInterval time.Duration
+}
+
+// Creates new ReplayConfig replaying whole input as fast as possible.
+func NewReplayConfig() *ReplayConfig {
+	return &ReplayConfig{
+		Speed:        ReplayAsFastAsPossible,
+		PollInterval: DefaultReplayPollInterval,
+	}
+}
+
+// Replay is a Source reading frames written by Recorder or DeadLetterFile, or any other input using
+// pg_recvlogical framing. It makes it possible to process recorded streams without Postgres.
+//
+// Replay finishes with io.EOF at the end of input unless it follows it. Read and decode errors wrap ErrSourceFailed.
+type Replay struct {
+	config ReplayConfig
+	open   func() (io.ReadCloser, error)
+
+	running   bool
+	closeOnce sync.Once
+	closeChan chan struct{}
+
+	msgChan   chan *decoderbufs.RowMessage
+	errEvents chan interface{}
+	finished  chan error
+}
+
+// Creates new Replay of frames read from reader.
+func NewReplay(reader io.Reader, config *ReplayConfig) *Replay {
+	return newReplay(config, func() (io.ReadCloser, error) {
+		return io.NopCloser(reader), nil
+	})
+}
+
+// Creates new Replay of segments written by Recorder to dir. Replay starts with the last segment
+// starting at or before StartPosition.
+func NewSegmentReplay(dir string, config *ReplayConfig) *Replay {
+	replay := newReplay(config, nil)
+	replay.open = func() (io.ReadCloser, error) {
+		return newSegmentReader(dir, replay.config.StartPosition)
+	}
+	return replay
+}
+
+func newReplay(config *ReplayConfig, open func() (io.ReadCloser, error)) *Replay {
+	replay := &Replay{
+		config:    *config,
+		open:      open,
+		closeChan: make(chan struct{}),
+		msgChan:   make(chan *decoderbufs.RowMessage),
+		errEvents: make(chan interface{}),
+		finished:  make(chan error, 1),
+	}
+	if replay.config.PollInterval <= 0 {
+		replay.config.PollInterval = DefaultReplayPollInterval
+	}
+	return replay
+}
+
+// ReplaySource returns SourceFactory replaying reader. Reader is consumed only once, so a restarted
+// Replay continues where the previous one stopped.
+func ReplaySource(reader io.Reader, config *ReplayConfig) SourceFactory {
+	return func(startPosition LogPos) (Source, error) {
+		return NewReplay(reader, replayConfigFrom(config, startPosition)), nil
+	}
+}
+
+// SegmentReplaySource returns SourceFactory replaying segments written by Recorder to dir.
+func SegmentReplaySource(dir string, config *ReplayConfig) SourceFactory {
+	return func(startPosition LogPos) (Source, error) {
+		return NewSegmentReplay(dir, replayConfigFrom(config, startPosition)), nil
+	}
+}
+
+func replayConfigFrom(config *ReplayConfig, startPosition LogPos) *ReplayConfig {
+	replayConfig := *config
+	if startPosition > replayConfig.StartPosition {
+		replayConfig.StartPosition = startPosition
+	}
+	return &replayConfig
+}
+
+// Start implements Source interface.
+func (r *Replay) Start() error {
+	if r.running {
+		return ErrStreamAlreadyRunning
+	}
+	r.running = true
+
+	go r.run()
+
+	return nil
+}
+
+// Close implements Source interface.
+func (r *Replay) Close() error {
+	r.closeOnce.Do(func() {
+		close(r.closeChan)
+	})
+	return nil
+}
+
+// Data implements Source interface. It is closed when input ends.
+func (r *Replay) Data() <-chan *decoderbufs.RowMessage {
+	return r.msgChan
+}
+
+// ErrOut implements Source interface. Replay produces no diagnostic messages.
+func (r *Replay) ErrOut() <-chan interface{} {
+	return r.errEvents
+}
+
+// Finished implements Source interface.
+func (r *Replay) Finished() <-chan error {
+	return r.finished
+}
+
+func (r *Replay) run() {
+	err := r.replay()
+	switch err {
+	case io.EOF:
+		close(r.msgChan)
+	case errReplayClosed:
+		err = nil
+	}
+	r.finished <- err
+}
+
+func (r *Replay) replay() error {
+	reader, err := r.open()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrSourceFailed, err)
+	}
+	defer reader.Close()
+
+	if r.config.Follow {
+		reader = &followReader{ReadCloser: reader, pollInterval: r.config.PollInterval, closeChan: r.closeChan}
+	}
+
+	var started time.Time
+	var firstCommit uint64
+	for {
+		data, err := readFrame(reader)
+		if err == io.EOF || err == errReplayClosed {
+			return err
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrSourceFailed, err)
+		}
+
+		msg := &decoderbufs.RowMessage{}
+		if err := proto.Unmarshal(data, msg); err != nil {
+			return fmt.Errorf("%w: %v", ErrSourceFailed, err)
+		}
+		if LogPos(msg.GetLogPosition()) < r.config.StartPosition {
+			continue
+		}
+
+		if r.config.Speed > 0 && msg.GetCommitTime() > 0 {
+			if firstCommit == 0 {
+				started, firstCommit = time.Now(), msg.GetCommitTime()
+			} else if msg.GetCommitTime() > firstCommit {
+				elapsed := time.Duration(float64(msg.GetCommitTime()-firstCommit) * float64(time.Microsecond) / r.config.Speed)
+				select {
+				case <-time.After(time.Until(started.Add(elapsed))):
+				case <-r.closeChan:
+					return errReplayClosed
+				}
+			}
+		}
+
+		select {
+		case r.msgChan <- msg:
+		case <-r.closeChan:
+			return errReplayClosed
+		}
+	}
+}
+
+// followReader waits for more data instead of returning io.EOF.
+type followReader struct {
+	io.ReadCloser
+	pollInterval time.Duration
+	closeChan    chan struct{}
+}
+
+func (f *followReader) Read(p []byte) (int, error) {
+	for {
+		n, err := f.ReadCloser.Read(p)
+		if n > 0 || err != io.EOF {
+			return n, err
+		}
+
+		select {
+		case <-time.After(f.pollInterval):
+		case <-f.closeChan:
+			return 0, errReplayClosed
+		}
+	}
+}
+
+// segmentReader reads segments one after another. When following, it checks for new segments
+// whenever the current one has no more data.
+type segmentReader struct {
+	dir string
+
+	current *Segment
+	reader  io.ReadCloser
+}
+
+func newSegmentReader(dir string, startPosition LogPos) (*segmentReader, error) {
+	segments, err := ListSegments(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := &segmentReader{dir: dir}
+	if len(segments) == 0 {
+		return reader, nil
+	}
+
+	first := segments[0]
+	for _, segment := range segments {
+		if segment.StartPos > startPosition {
+			break
+		}
+		first = segment
+	}
+
+	return reader, reader.openSegment(first)
+}
+
+func (s *segmentReader) openSegment(segment *Segment) error {
+	reader, err := segment.Open()
+	if err != nil {
+		return err
+	}
+	if s.reader != nil {
+		s.reader.Close()
+	}
+	s.current = segment
+	s.reader = reader
+	return nil
+}
+
+// nextSegment returns segment following the current one or nil if there is none yet.
+func (s *segmentReader) nextSegment() (*Segment, error) {
+	segments, err := ListSegments(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	if s.current == nil {
+		if len(segments) > 0 {
+			return segments[0], nil
+		}
+		return nil, nil
+	}
+	for i, segment := range segments {
+		if segment.Path == s.current.Path && i+1 < len(segments) {
+			return segments[i+1], nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *segmentReader) Read(p []byte) (int, error) {
+	for {
+		if s.reader != nil {
+			n, err := s.reader.Read(p)
+			if n > 0 || err != io.EOF {
+				return n, err
+			}
+		}
+
+		next, err := s.nextSegment()
+		if err != nil {
+			return 0, err
+		}
+		if next == nil {
+			return 0, io.EOF
+		}
+		// Current segment may have been written to before the next one was started.
+		if s.reader != nil {
+			if n, err := s.reader.Read(p); n > 0 || err != io.EOF {
+				return n, err
+			}
+		}
+		if err := s.openSegment(next); err != nil {
+			return 0, err
+		}
+	}
+}
+
+func (s *segmentReader) Close() error {
+	if s.reader == nil {
+		return nil
+	}
+	return s.reader.Close()
+}
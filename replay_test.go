@@ -0,0 +1,234 @@
+package llsr
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/liquidm/llsr/decoderbufs"
+)
+
+func testRecording(t *testing.T, positions ...uint64) *bytes.Buffer {
+	var messages []*decoderbufs.RowMessage
+	for _, pos := range positions {
+		messages = append(messages, testMessage(pos, "users", int32(pos)))
+	}
+	return testMessageRecording(t, messages...)
+}
+
+func testMessageRecording(t *testing.T, messages ...*decoderbufs.RowMessage) *bytes.Buffer {
+	var buf bytes.Buffer
+	for _, msg := range messages {
+		if err := writeFrame(&buf, testMessageFrame(t, msg)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &buf
+}
+
+func expectReplay(t *testing.T, replay *Replay, positions ...uint64) {
+	for _, expected := range positions {
+		select {
+		case msg := <-replay.Data():
+			if msg == nil || msg.GetLogPosition() != expected {
+				t.Fatalf("Expected message at %d, got %v", expected, msg)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timeout")
+		}
+	}
+}
+
+func TestReplaySeek(t *testing.T) {
+	config := NewReplayConfig()
+	config.StartPosition = 3
+
+	replay := NewReplay(testRecording(t, 1, 2, 3, 4, 5), config)
+	if err := replay.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	expectReplay(t, replay, 3, 4, 5)
+	if _, ok := <-replay.Data(); ok {
+		t.Fatal("Expected Data to be closed")
+	}
+	if err := <-replay.Finished(); err != io.EOF {
+		t.Fatalf("Expected io.EOF, got %v", err)
+	}
+}
+
+func TestReplayCorruptedInput(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeFrame(&buf, []byte{0xff, 0xff}); err != nil {
+		t.Fatal(err)
+	}
+
+	replay := NewReplay(&buf, NewReplayConfig())
+	if err := replay.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := <-replay.Finished(); !errors.Is(err, ErrSourceFailed) {
+		t.Fatalf("Expected ErrSourceFailed, got %v", err)
+	}
+}
+
+func TestReplayPacing(t *testing.T) {
+	var buf bytes.Buffer
+	for i := uint64(0); i < 3; i++ {
+		data, err := proto.Marshal(&decoderbufs.RowMessage{
+			LogPosition: proto.Uint64(i + 1),
+			CommitTime:  proto.Uint64(1e15 + i*50000),
+			Table:       proto.String("users"),
+			Op:          decoderbufs.Op_INSERT.Enum(),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := writeFrame(&buf, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	config := NewReplayConfig()
+	config.Speed = ReplayRealTime
+
+	replay := NewReplay(&buf, config)
+	started := time.Now()
+	if err := replay.Start(); err != nil {
+		t.Fatal(err)
+	}
+	expectReplay(t, replay, 1, 2, 3)
+
+	if elapsed := time.Since(started); elapsed < 100*time.Millisecond {
+		t.Fatalf("Expected replay to take at least 100ms, took %v", elapsed)
+	}
+}
+
+func TestReplayFollow(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "recording")
+	if err := os.WriteFile(path, testRecording(t, 1).Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	config := NewReplayConfig()
+	config.Follow = true
+	config.PollInterval = 10 * time.Millisecond
+
+	replay := NewReplay(file, config)
+	if err := replay.Start(); err != nil {
+		t.Fatal(err)
+	}
+	expectReplay(t, replay, 1)
+
+	writer, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := writer.Write(testRecording(t, 2).Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	writer.Close()
+
+	expectReplay(t, replay, 2)
+
+	replay.Close()
+	if err := <-replay.Finished(); err != nil {
+		t.Fatalf("Expected nil, got %v", err)
+	}
+}
+
+func TestSegmentReplay(t *testing.T) {
+	recorderConfig := NewRecorderConfig(t.TempDir())
+	recorderConfig.SegmentSize = int64(2 * (len(testFrame(t, 1)) + 9))
+	recorderConfig.Compress = true
+	testRecord(t, recorderConfig, 1, 2, 3, 4, 5)
+
+	config := NewReplayConfig()
+	config.StartPosition = 4
+
+	replay := NewSegmentReplay(recorderConfig.Dir, config)
+	if err := replay.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	expectReplay(t, replay, 4, 5)
+	if err := <-replay.Finished(); err != io.EOF {
+		t.Fatalf("Expected io.EOF, got %v", err)
+	}
+}
+
+func TestClientWithReplaySource(t *testing.T) {
+	config := NewClientConfig(nil, &DummyConverter{}, "llsr_test_slot")
+	config.Source = ReplaySource(testRecording(t, 1, 2, 3), NewReplayConfig())
+
+	c, err := NewClientWithConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	for i := 0; i < 3; i++ {
+		expectClientUpdate(t, c, "INSERT users")
+	}
+
+	select {
+	case event := <-c.Events():
+		if event.Type != EventEndOfStream || event.Value != LogPos(3) {
+			t.Fatalf("Expected EventEndOfStream at 3, got %v %v", event.Type, event.Value)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timeout")
+	}
+}
+
+func TestClientWithoutDatabaseRequiresSource(t *testing.T) {
+	if _, err := NewClientWithConfig(NewClientConfig(nil, &DummyConverter{}, "llsr_test_slot")); err != ErrNoDatabase {
+		t.Fatalf("Expected ErrNoDatabase, got %v", err)
+	}
+}
+
+func TestClientWithEndPosition(t *testing.T) {
+	config := NewClientConfig(nil, &DummyConverter{}, "llsr_test_slot")
+	config.Source = ReplaySource(testRecording(t, 1, 2, 3, 4, 5), NewReplayConfig())
+	config.EndPosition = 3
+
+	c, err := NewClientWithConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	for i := 0; i < 3; i++ {
+		expectClientUpdate(t, c, "INSERT users")
+	}
+
+	select {
+	case update, ok := <-c.Updates():
+		if ok {
+			t.Fatalf("Expected Updates to be closed, got %v", update)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timeout")
+	}
+
+	select {
+	case event := <-c.Events():
+		if event.Type != EventEndOfStream || event.Value != LogPos(3) {
+			t.Fatalf("Expected EventEndOfStream at 3, got %v %v", event.Type, event.Value)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timeout")
+	}
+}
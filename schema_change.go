@@ -0,0 +1,46 @@
+package llsr
+
+import (
+	"sync"
+)
+
+// SchemaChange is Value of EventSchemaChanged event. It describes table which columns differ
+// from the cached description.
+type SchemaChange struct {
+	Table      string
+	OldColumns []*Column
+	NewColumns []*Column
+
+	ackOnce sync.Once
+	acked   chan struct{}
+}
+
+func newSchemaChange(table string, oldRelation, newRelation *Relation) *SchemaChange {
+	return &SchemaChange{
+		Table:      table,
+		OldColumns: oldRelation.Columns,
+		NewColumns: newRelation.Columns,
+		acked:      make(chan struct{}),
+	}
+}
+
+// Ack acknowledges schema change. When ClientConfig.PauseOnSchemaChange is set, Client does not
+// deliver further updates until Ack is called. It is safe to call Ack multiple times.
+func (s *SchemaChange) Ack() {
+	s.ackOnce.Do(func() {
+		close(s.acked)
+	})
+}
+
+// sameColumns reports whether both column lists have the same names, types and type modifiers.
+func sameColumns(a, b []*Column) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Name != b[i].Name || a[i].Type != b[i].Type || a[i].TypeMod != b[i].TypeMod {
+			return false
+		}
+	}
+	return true
+}
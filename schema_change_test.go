@@ -0,0 +1,116 @@
+package llsr
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/liquidm/llsr/decoderbufs"
+)
+
+func TestSameColumns(t *testing.T) {
+	columns := testRelation().Columns
+
+	if !sameColumns(columns, testRelation().Columns) {
+		t.Fatal("Expected equal column lists to be the same")
+	}
+
+	if sameColumns(columns, columns[:1]) {
+		t.Fatal("Expected column lists of different length to differ")
+	}
+
+	changed := testRelation().Columns
+	changed[1].Type = 1043
+	if sameColumns(columns, changed) {
+		t.Fatal("Expected column lists with different types to differ")
+	}
+}
+
+func TestSchemaChangeAck(t *testing.T) {
+	change := newSchemaChange("public.llsr_test_table", testRelation(), testRelation())
+
+	change.Ack()
+	change.Ack()
+
+	select {
+	case <-change.acked:
+	case <-time.After(time.Second):
+		t.Fatal("Expected Ack() to release waiting client")
+	}
+}
+
+func TestClientSchemaChangeWithUnknownType(t *testing.T) {
+	withTestConnection(t, func(t *testing.T, db *sql.DB) {
+		if _, err := db.Exec("CREATE TYPE llsr_test_mood AS ENUM ('happy', 'sad')"); err != nil {
+			t.Fatal(err)
+		}
+		defer db.Exec("DROP TYPE llsr_test_mood")
+
+		config := NewClientConfig(testConfig(), &DummyConverter{}, "llsr_test_slot")
+		config.ValuesMapReloadInterval = time.Millisecond
+		c, err := NewClientWithConfig(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+		go func() {
+			for range c.Updates() {
+			}
+		}()
+
+		if _, err := db.Exec("INSERT INTO llsr_test_table (id, txt) VALUES(1, 'foo')"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Exec("ALTER TABLE llsr_test_table ADD COLUMN mood llsr_test_mood"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Exec("INSERT INTO llsr_test_table (id, txt, mood) VALUES(2, 'bar', 'happy')"); err != nil {
+			t.Fatal(err)
+		}
+
+		expectClientEvent(t, c, EventSchemaChanged)
+	})
+}
+
+func TestCheckRelationReloadsOncePerShape(t *testing.T) {
+	db, err := sql.Open("postgres", "host=127.0.0.1 port=1 sslmode=disable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	relations := newRelationCache(db)
+	c := &client{catalog: &Catalog{Relations: relations}}
+	seed := func() {
+		relations.relations["llsr_test_table"] = testRelation()
+	}
+	cached := func() bool {
+		_, ok := relations.relations["llsr_test_table"]
+		return ok
+	}
+
+	message := &decoderbufs.RowMessage{
+		Table:    proto.String("llsr_test_table"),
+		Op:       decoderbufs.Op_INSERT.Enum(),
+		NewTuple: []*decoderbufs.DatumMessage{testDatum("id", 23), testDatum("txt", 25), testDatum("dropped", 25)},
+	}
+
+	seed()
+	c.checkRelation(message)
+	if cached() {
+		t.Fatal("Expected first mismatching message to reload relation")
+	}
+
+	seed()
+	c.checkRelation(message)
+	if !cached() {
+		t.Fatal("Expected message of the same shape not to reload relation again")
+	}
+
+	message.NewTuple = message.NewTuple[:1]
+	c.checkRelation(message)
+	if cached() {
+		t.Fatal("Expected message of another shape to reload relation")
+	}
+}
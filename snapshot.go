@@ -0,0 +1,241 @@
+package llsr
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/liquidm/llsr/decoderbufs"
+)
+
+// Default number of rows fetched at once while reading snapshot.
+const DefaultSnapshotChunkSize = 1000
+
+const outputPlugin = "decoderbufs"
+
+var (
+	ErrSnapshotInterrupted = errors.New("llsr: Snapshot interrupted by Close()")
+)
+
+// OpRead marks rows read by snapshot rather than streamed from the slot. decoderbufs does not define it,
+// so it only appears in messages created by Client. Its value is kept away from ops of decoderbufs forks,
+// e.g. Debezium's BEGIN and COMMIT. Use OpName to print it.
+const OpRead decoderbufs.Op = 100
+
+// OpName returns name of op, e.g. "INSERT" or "READ" for OpRead.
+func OpName(op decoderbufs.Op) string {
+	if op == OpRead {
+		return "READ"
+	}
+	return op.String()
+}
+
+// Configuration of initial snapshot. When set in ClientConfig, Client creates the replication slot
+// with exported snapshot, emits current contents of Tables through Converter and then streams changes
+// from the slot's consistent point. The slot is dropped when snapshot fails or is interrupted by Close,
+// so the Client may be started again with the same config.
+type SnapshotConfig struct {
+	// Tables to read, e.g. "public.users". Rows are emitted with schema qualified table name.
+	Tables []string
+	// ChunkSize is the number of rows fetched at once.
+	ChunkSize int
+	// Op is set on every snapshot RowMessage.
+	Op decoderbufs.Op
+}
+
+// Creates new SnapshotConfig for given tables. Rows are emitted as OpRead.
+func NewSnapshotConfig(tables ...string) *SnapshotConfig {
+	return &SnapshotConfig{
+		Tables:    tables,
+		ChunkSize: DefaultSnapshotChunkSize,
+		Op:        OpRead,
+	}
+}
+
+// createSlotWithSnapshot creates logical replication slot and exports snapshot consistent with its starting point.
+// Snapshot stays valid as long as returned connection is open and unused.
+func createSlotWithSnapshot(dbConfig *DatabaseConfig, slot string) (*sql.Conn, LogPos, string, error) {
+	db := dbConfig.open("replication=database")
+	// Closing db is deferred until returned connection is closed.
+	defer db.Close()
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		return nil, 0, "", err
+	}
+
+	var slotName, consistentPoint, snapshotName, plugin string
+	err = conn.QueryRowContext(context.Background(), "CREATE_REPLICATION_SLOT "+pq.QuoteIdentifier(slot)+" LOGICAL "+outputPlugin+" EXPORT_SNAPSHOT").
+		Scan(&slotName, &consistentPoint, &snapshotName, &plugin)
+	if err != nil {
+		conn.Close()
+		return nil, 0, "", err
+	}
+
+	pos, err := ParseLogPos(consistentPoint)
+	if err != nil {
+		conn.Close()
+		return nil, 0, "", err
+	}
+
+	return conn, pos, snapshotName, nil
+}
+
+// startWithSnapshot creates slot and reads snapshot in background. Streaming starts once snapshot is read.
+func (c *client) startWithSnapshot() error {
+	conn, consistentPoint, snapshotName, err := createSlotWithSnapshot(c.dbConfig, c.slot)
+	if err != nil {
+		return err
+	}
+
+	c.spawn(func() {
+		c.runSnapshot(conn, consistentPoint, snapshotName)
+	})
+
+	return nil
+}
+
+func (c *client) runSnapshot(conn *sql.Conn, consistentPoint LogPos, snapshotName string) {
+	err := c.readSnapshot(snapshotName, consistentPoint)
+	conn.Close()
+
+	// Consistent point of the slot is past rows which were not emitted, so the slot is useless.
+	if err == ErrSnapshotInterrupted {
+		c.dropSlot()
+		c.closeUpdates()
+		return
+	}
+
+	if err == nil {
+		c.startPosition = consistentPoint
+		err = c.start()
+	} else {
+		c.dropSlot()
+	}
+
+	if err != nil {
+		c.setErr(err)
+		c.closeUpdates()
+		c.emit(&Event{Type: EventSnapshotFailed, Value: err})
+		return
+	}
+
+	c.emit(&Event{Type: EventSnapshotCompleted, Value: consistentPoint})
+}
+
+// dropSlot drops the slot created for snapshot.
+func (c *client) dropSlot() {
+	c.db.Exec("SELECT pg_drop_replication_slot($1)", c.slot)
+}
+
+func (c *client) readSnapshot(snapshotName string, consistentPoint LogPos) error {
+	tx, err := c.db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("SET TRANSACTION SNAPSHOT " + pq.QuoteLiteral(snapshotName)); err != nil {
+		return err
+	}
+
+	for _, table := range c.config.Snapshot.Tables {
+		if err := c.readSnapshotTable(tx, table, consistentPoint); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (c *client) readSnapshotTable(tx *sql.Tx, table string, consistentPoint LogPos) error {
+	relation, err := c.catalog.Relations.Get(table)
+	if err != nil {
+		return err
+	}
+
+	columns := make([]string, len(relation.Columns))
+	for i, column := range relation.Columns {
+		columns[i] = pq.QuoteIdentifier(column.Name) + "::text"
+	}
+
+	_, err = tx.Exec("DECLARE llsr_snapshot NO SCROLL CURSOR FOR SELECT " + strings.Join(columns, ", ") + " FROM " + relation.QualifiedName)
+	if err != nil {
+		return err
+	}
+
+	chunkSize := c.config.Snapshot.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = DefaultSnapshotChunkSize
+	}
+
+	for {
+		chunk, _, err := readRows(tx, relation, "FETCH FORWARD "+strconv.Itoa(chunkSize)+" FROM llsr_snapshot")
+		if err != nil {
+			return err
+		}
+
+		for _, tuple := range chunk {
+			op := c.config.Snapshot.Op
+			logPosition := uint64(consistentPoint)
+			tableName := relation.QualifiedName
+			row := &decoderbufs.RowMessage{
+				LogPosition: &logPosition,
+				Table:       &tableName,
+				Op:          &op,
+				NewTuple:    tuple,
+			}
+
+			if !c.deliver(row) {
+				return ErrSnapshotInterrupted
+			}
+		}
+
+		if len(chunk) < chunkSize {
+			break
+		}
+	}
+
+	_, err = tx.Exec("CLOSE llsr_snapshot")
+	return err
+}
+
+type queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// readRows runs query returning relation columns as text and converts result into tuples.
+// It also returns text values of the last row.
+func readRows(db queryer, relation *Relation, query string, args ...interface{}) ([][]*decoderbufs.DatumMessage, []sql.NullString, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	values := make([]sql.NullString, len(relation.Columns))
+	pointers := make([]interface{}, len(relation.Columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+
+	var tuples [][]*decoderbufs.DatumMessage
+	for rows.Next() {
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, nil, err
+		}
+
+		tuple := make([]*decoderbufs.DatumMessage, len(relation.Columns))
+		for i, column := range relation.Columns {
+			if tuple[i], err = textDatum(column, values[i]); err != nil {
+				return nil, nil, err
+			}
+		}
+		tuples = append(tuples, tuple)
+	}
+
+	return tuples, values, rows.Err()
+}
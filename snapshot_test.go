@@ -0,0 +1,125 @@
+package llsr
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/liquidm/llsr/decoderbufs"
+)
+
+func TestNewSnapshotConfig(t *testing.T) {
+	config := NewSnapshotConfig("public.users", "public.orders")
+
+	if len(config.Tables) != 2 || config.Tables[0] != "public.users" {
+		t.Fatal("Expected NewSnapshotConfig to set Tables attribute")
+	}
+
+	if config.ChunkSize != DefaultSnapshotChunkSize {
+		t.Fatal("Expected NewSnapshotConfig to set default ChunkSize")
+	}
+
+	if config.Op != OpRead {
+		t.Fatal("Expected NewSnapshotConfig to emit rows as READ")
+	}
+}
+
+func TestOpName(t *testing.T) {
+	if name := OpName(OpRead); name != "READ" {
+		t.Fatalf("Expected OpRead to be named READ. Got: %s", name)
+	}
+
+	if name := OpName(decoderbufs.Op_DELETE); name != "DELETE" {
+		t.Fatalf("Expected decoderbufs ops to keep their names. Got: %s", name)
+	}
+}
+
+func TestClientSnapshot(t *testing.T) {
+	db, err := sql.Open("postgres", testConfig().ToConnectionString())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec("CREATE TABLE llsr_test_table (id int primary key, txt text NOT NULL);")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Exec("DROP TABLE llsr_test_table")
+
+	_, err = db.Exec("INSERT INTO llsr_test_table (id, txt) SELECT i, 'foo' FROM generate_series(1, 5) i")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := NewClientConfig(testConfig(), &DummyConverter{}, "llsr_test_slot")
+	config.Snapshot = NewSnapshotConfig("public.llsr_test_table")
+	config.Snapshot.ChunkSize = 2
+	config.Snapshot.Op = decoderbufs.Op_INSERT
+
+	client, err := NewClientWithConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Exec("SELECT * FROM pg_drop_replication_slot('llsr_test_slot')")
+	defer client.Close()
+
+	for i := 0; i < 5; i++ {
+		expectClientUpdate(t, client, "INSERT public.llsr_test_table")
+	}
+
+	expectClientEvent(t, client, EventSnapshotCompleted)
+
+	_, err = db.Exec("UPDATE llsr_test_table SET txt = 'bar' WHERE id = 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectClientUpdate(t, client, "UPDATE llsr_test_table")
+
+	select {
+	case update := <-client.Updates():
+		t.Fatalf("Expected no duplicated changes after snapshot. Got: %v", update)
+	case <-time.After(time.Second):
+	}
+}
+
+func TestClientSnapshotInterruptedDropsSlot(t *testing.T) {
+	db, err := sql.Open("postgres", testConfig().ToConnectionString())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec("CREATE TABLE llsr_test_table (id int primary key, txt text NOT NULL);")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Exec("DROP TABLE llsr_test_table")
+
+	_, err = db.Exec("INSERT INTO llsr_test_table (id, txt) SELECT i, 'foo' FROM generate_series(1, 5) i")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := NewClientConfig(testConfig(), &DummyConverter{}, "llsr_test_slot")
+	config.Snapshot = NewSnapshotConfig("public.llsr_test_table")
+	config.Snapshot.ChunkSize = 2
+
+	client, err := NewClientWithConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Exec("SELECT * FROM pg_drop_replication_slot('llsr_test_slot')")
+
+	<-client.Updates()
+	client.Close()
+
+	var slots int
+	if err := db.QueryRow("SELECT count(*) FROM pg_replication_slots WHERE slot_name = 'llsr_test_slot'").Scan(&slots); err != nil {
+		t.Fatal(err)
+	}
+	if slots != 0 {
+		t.Fatal("Expected slot of interrupted snapshot to be dropped")
+	}
+}
@@ -0,0 +1,33 @@
+package llsr
+
+import (
+	"errors"
+
+	"github.com/liquidm/llsr/decoderbufs"
+)
+
+var (
+	ErrSourceFailed = errors.New("llsr: Source failed")
+	ErrNoDatabase   = errors.New("llsr: Client has no database connection")
+)
+
+// Source produces RowMessages processed by Client. Stream running pg_recvlogical is the default Source.
+//
+// Finished produces nil or an error when Source stops. Client starts new Source from the last received
+// position unless it was closed, the error is io.EOF, or the error wraps ErrSourceFailed. Source which
+// has no more messages closes its Data channel and reports io.EOF; Client then dispatches EventEndOfStream.
+type Source interface {
+	// Start begins producing messages. It does not block.
+	Start() error
+	// Close stops Source. It does not block; Finished produces a value once Source is stopped.
+	Close() error
+	// Data produces received messages.
+	Data() <-chan *decoderbufs.RowMessage
+	// ErrOut produces diagnostic messages, each terminated with a new line.
+	ErrOut() <-chan interface{}
+	// Finished produces the reason Source stopped.
+	Finished() <-chan error
+}
+
+// SourceFactory creates Source starting at given log position. It is called on every (re)connect.
+type SourceFactory func(startPosition LogPos) (Source, error)
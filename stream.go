@@ -2,12 +2,14 @@ package llsr
 
 import (
 	"bufio"
-	"encoding/binary"
 	"errors"
-	"fmt"
 	"io"
 	"os"
 	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/golang/protobuf/proto"
 	"github.com/liquidm/llsr/decoderbufs"
@@ -32,36 +34,64 @@ type Stream struct {
 
 	msgChan chan *decoderbufs.RowMessage
 
+	//finished is sent to once, by wait
 	finished     chan error
+	errLock      sync.Mutex
 	runtimeError error
+
+	recorder *Recorder
+
+	dbConfig *DatabaseConfig
+	passfile string
+
+	metrics *Metrics
+
+	endPos     LogPos
+	closing    chan struct{}
+	closeOnce  sync.Once
+	outputDone chan struct{}
+	errDone    chan struct{}
 }
 
-//Creates new Stream object
+//Creates new Stream object running pg_recvlogical with its defaults
 func NewStream(dbConfig *DatabaseConfig, slot string, startPos LogPos) *Stream {
-	cmd := exec.Command("pg_recvlogical", "--start", "--file=-", "-S", slot, "-d", dbConfig.Database, "-F", "0")
+	stream, _ := NewStreamWithConfig(dbConfig, slot, startPos, NewStreamConfig())
+	return stream
+}
+
+//Creates new Stream object using given StreamConfig. It returns error when config is invalid.
+func NewStreamWithConfig(dbConfig *DatabaseConfig, slot string, startPos LogPos, config *StreamConfig) (*Stream, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command(config.binary(), config.args(slot, startPos)...)
+	if len(dbConfig.Database) > 0 {
+		cmd.Args = append(cmd.Args, "-d", dbConfig.Database)
+	}
 	if len(dbConfig.User) > 0 {
 		cmd.Args = append(cmd.Args, "-U", dbConfig.User)
 	}
-	if len(dbConfig.Host) > 0 {
-		cmd.Args = append(cmd.Args, "-h", dbConfig.Host)
-	}
+	//Host is always passed, so pg_recvlogical connects where database/sql connections do
+	cmd.Args = append(cmd.Args, "-h", dbConfig.host())
 	if dbConfig.Port > 0 {
-		cmd.Args = append(cmd.Args, "-p", string(dbConfig.Port))
-	}
-	if len(dbConfig.Password) > 0 {
-		cmd.Env = append(os.Environ(), fmt.Sprintf("PGPASSWORD=%s", dbConfig.Password))
-	}
-	if startPos > 0 {
-		cmd.Args = append(cmd.Args, "-I", startPos.String())
+		cmd.Args = append(cmd.Args, "-p", strconv.Itoa(dbConfig.Port))
 	}
+	cmd.Args = append(cmd.Args, config.ExtraArgs...)
 	stream := &Stream{
 		cmd:        cmd,
-		finished:   make(chan error),
+		finished:   make(chan error, 1),
 		errEvents:  make(chan interface{}),
-		dataEvents: make(chan interface{}),
-		msgChan:    make(chan *decoderbufs.RowMessage),
+		dataEvents: make(chan interface{}, config.FrameBuffer),
+		msgChan:    make(chan *decoderbufs.RowMessage, config.MessageBuffer),
+		metrics:    config.Metrics,
+		dbConfig:   dbConfig,
+		endPos:     config.EndPos,
+		closing:    make(chan struct{}),
+		outputDone: make(chan struct{}),
+		errDone:    make(chan struct{}),
 	}
-	return stream
+	return stream, nil
 }
 
 //Establishes connection with PostgreSQL LLSR
@@ -72,6 +102,11 @@ func (s *Stream) Start() error {
 		return ErrStreamAlreadyRunning
 	}
 
+	err = s.setEnv()
+	if err != nil {
+		return err
+	}
+
 	s.stdOut, err = s.cmd.StdoutPipe()
 	if err != nil {
 		return err
@@ -84,6 +119,7 @@ func (s *Stream) Start() error {
 
 	err = s.cmd.Start()
 	if err != nil {
+		s.removePassfile()
 		return err
 	}
 
@@ -97,18 +133,63 @@ func (s *Stream) Start() error {
 	return nil
 }
 
+//Sets environment of pg_recvlogical. Password is written to temporary passfile, so it is not visible in /proc/<pid>/environ.
+func (s *Stream) setEnv() error {
+	dbConfig, err := s.dbConfig.resolve()
+	if err != nil {
+		return err
+	}
+
+	s.passfile, err = dbConfig.writePassfile()
+	if err != nil {
+		return err
+	}
+
+	env := os.Environ()
+	if len(s.passfile) > 0 {
+		inherited := env
+		env = make([]string, 0, len(inherited)+1)
+		for _, variable := range inherited {
+			if !strings.HasPrefix(variable, "PGPASSWORD=") && !strings.HasPrefix(variable, "PGPASSFILE=") {
+				env = append(env, variable)
+			}
+		}
+		env = append(env, "PGPASSFILE="+s.passfile)
+	}
+	s.cmd.Env = append(env, dbConfig.environ()...)
+	return nil
+}
+
+func (s *Stream) removePassfile() {
+	if len(s.passfile) > 0 {
+		os.Remove(s.passfile)
+		s.passfile = ""
+	}
+}
+
+//SetRecorder makes Stream write every received frame to recorder. It must be called before Start.
+//Recording errors do not stop the stream; check Recorder.Err.
+func (s *Stream) SetRecorder(recorder *Recorder) {
+	s.recorder = recorder
+}
+
 //Closes connection with LLSR. It does not block. You should wait on Finished channel to ensure stream is closed.
+//Messages not read from Data channel yet are dropped.
 func (s *Stream) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.closing)
+	})
 	return s.cmd.Process.Signal(os.Interrupt)
 }
 
 //Finished channel produces error message when underlying pg_recvlogical exits with error.
-//It produces nil when pg_recvlogical exits with 0 (e.g when Close() was called)
+//It produces nil when pg_recvlogical exits with 0 (e.g when Close() was called or end position was reached)
 func (s *Stream) Finished() <-chan error {
 	return s.finished
 }
 
 //Data channel produces RowMessage objects.
+//When StreamConfig.EndPos is set and pg_recvlogical reaches it, Data is closed after the last message.
 func (s *Stream) Data() <-chan *decoderbufs.RowMessage {
 	return s.msgChan
 }
@@ -119,11 +200,17 @@ func (s *Stream) ErrOut() <-chan interface{} {
 }
 
 func (s *Stream) recvErrors() {
+	defer close(s.errDone)
+
 	reader := bufio.NewReader(s.stdErr)
 	for {
 		str, err := reader.ReadString('\n')
 		if len(str) > 0 {
-			s.errEvents <- str
+			select {
+			case s.errEvents <- str:
+			case <-s.closing:
+				return
+			}
 		}
 		if err == io.EOF {
 			return
@@ -137,30 +224,51 @@ func (s *Stream) recvErrors() {
 
 func (s *Stream) recvData() {
 	for {
-		var length uint64
-		err := binary.Read(s.stdOut, binary.BigEndian, &length)
+		data, err := readFrame(s.stdOut)
 		if err != nil {
 			if err == io.EOF {
+				close(s.dataEvents)
 				return
 			}
 			s.stopWith(err)
 			return
 		}
-		data := make([]byte, length+1)
 
-		_, err = io.ReadFull(s.stdOut, data)
-		if err != nil {
-			s.stopWith(err)
-			return
+		if s.recorder != nil {
+			s.recorder.Record(data)
+		}
+
+		select {
+		case s.dataEvents <- data:
+			continue
+		default:
 		}
 
-		s.dataEvents <- data[:length]
+		start := time.Now()
+		select {
+		case s.dataEvents <- data:
+		case <-s.closing:
+			return
+		}
+		s.metrics.blocked(stageReader, start)
 	}
 }
 
 func (s *Stream) convertData() {
 	for {
-		data := (<-s.dataEvents).([]byte)
+		var event interface{}
+		var ok bool
+		select {
+		case event, ok = <-s.dataEvents:
+			if !ok {
+				close(s.outputDone)
+				return
+			}
+		case <-s.closing:
+			return
+		}
+
+		data := event.([]byte)
 		decodedData := &decoderbufs.RowMessage{}
 
 		err := proto.Unmarshal(data, decodedData)
@@ -169,26 +277,56 @@ func (s *Stream) convertData() {
 			return
 		}
 
-		s.msgChan <- decodedData
+		select {
+		case s.msgChan <- decodedData:
+			continue
+		default:
+		}
+
+		start := time.Now()
+		select {
+		case s.msgChan <- decodedData:
+		case <-s.closing:
+			return
+		}
+		s.metrics.blocked(stageDecoder, start)
 	}
 }
 
 func (s *Stream) wait() {
+	//pg_recvlogical exits on its own only at end position; all its output must be delivered before Data is closed.
+	if s.endPos > 0 {
+		select {
+		case <-s.outputDone:
+		case <-s.closing:
+		}
+	}
+	//Wait closes the pipes, so stderr must be read to the end first
+	<-s.errDone
+
 	err := s.cmd.Wait()
+	s.removePassfile()
 	if err == nil {
+		s.errLock.Lock()
 		err = s.runtimeError
-		s.runtimeError = nil
+		s.errLock.Unlock()
+	}
+	if err == nil && s.endPos > 0 {
+		select {
+		case <-s.closing:
+		default:
+			close(s.msgChan)
+		}
 	}
 	s.finished <- err
 }
 
+//stopWith records the first error which broke the stream and stops pg_recvlogical. It is reported by Finished.
 func (s *Stream) stopWith(err error) {
-	s.runtimeError = err
-	stopErr := s.Close()
-	if stopErr != nil {
-		select {
-		case s.finished <- err:
-		default:
-		}
+	s.errLock.Lock()
+	if s.runtimeError == nil {
+		s.runtimeError = err
 	}
+	s.errLock.Unlock()
+	s.Close()
 }
@@ -0,0 +1,142 @@
+package llsr
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Default pg_recvlogical binary, looked up in PATH.
+const DefaultRecvLogicalBinary = "pg_recvlogical"
+
+var (
+	ErrInvalidStreamConfig = errors.New("llsr: Invalid stream configuration")
+)
+
+// Options set by Stream itself, which must not be passed in StreamConfig.ExtraArgs.
+var managedRecvLogicalOptions = map[string]bool{
+	"--start": true, "--create-slot": true, "--drop-slot": true, "--if-not-exists": true,
+	"-f": true, "--file": true, "-F": true, "--fsync-interval": true,
+	"-S": true, "--slot": true, "-I": true, "--startpos": true, "-E": true, "--endpos": true,
+	"-o": true, "--option": true, "-P": true, "--plugin": true, "-s": true, "--status-interval": true,
+	"-d": true, "--dbname": true, "-U": true, "--username": true, "-h": true, "--host": true, "-p": true, "--port": true,
+	"-W": true, "--password": true,
+}
+
+// Configuration of pg_recvlogical process run by Stream.
+type StreamConfig struct {
+	// Binary is the pg_recvlogical executable. DefaultRecvLogicalBinary is used when empty.
+	Binary string
+	// PluginOptions are passed to decoderbufs as -o name=value. Empty value passes -o name.
+	PluginOptions map[string]string
+	// StatusInterval is the time between status packets sent to the server. It must be whole seconds.
+	// Zero leaves pg_recvlogical default.
+	StatusInterval time.Duration
+	// FsyncInterval is the time between reports of flushed position. It must be whole seconds.
+	// Zero disables fsync calls, output is never a file anyway.
+	FsyncInterval time.Duration
+	// EndPos makes pg_recvlogical stop once it reaches given position. Zero streams forever.
+	EndPos LogPos
+	// CreateSlot makes pg_recvlogical create the slot with decoderbufs plugin before streaming.
+	CreateSlot bool
+	// IfNotExists makes CreateSlot succeed when the slot already exists.
+	IfNotExists bool
+	// ExtraArgs are appended to pg_recvlogical arguments, e.g. "-v". Options set by Stream are rejected.
+	ExtraArgs []string
+
+	// FrameBuffer is the number of raw frames read ahead of the protobuf decoder. Zero runs them in lock step.
+	FrameBuffer int
+	// MessageBuffer is the number of decoded messages waiting to be taken from Data. Zero runs decoder
+	// in lock step with the consumer.
+	MessageBuffer int
+	// Metrics records time reader and decoder spent blocked. Client sets it to ClientConfig.Metrics when nil.
+	Metrics *Metrics
+}
+
+// Creates new StreamConfig running pg_recvlogical with its defaults.
+func NewStreamConfig() *StreamConfig {
+	return &StreamConfig{
+		Binary: DefaultRecvLogicalBinary,
+	}
+}
+
+// Validate checks options before pg_recvlogical is started, as it reports them only on its stderr.
+func (c *StreamConfig) Validate() error {
+	for name := range c.PluginOptions {
+		if name == "" || strings.ContainsAny(name, "= ") {
+			return fmt.Errorf("%w: invalid plugin option name %q", ErrInvalidStreamConfig, name)
+		}
+	}
+	for option, interval := range map[string]time.Duration{"StatusInterval": c.StatusInterval, "FsyncInterval": c.FsyncInterval} {
+		if interval < 0 || interval%time.Second != 0 {
+			return fmt.Errorf("%w: %s must be a non-negative number of seconds, got %v", ErrInvalidStreamConfig, option, interval)
+		}
+	}
+	if c.FrameBuffer < 0 || c.MessageBuffer < 0 {
+		return fmt.Errorf("%w: buffer sizes must not be negative", ErrInvalidStreamConfig)
+	}
+	if c.IfNotExists && !c.CreateSlot {
+		return fmt.Errorf("%w: IfNotExists requires CreateSlot", ErrInvalidStreamConfig)
+	}
+	for _, arg := range c.ExtraArgs {
+		if managedRecvLogicalOptions[recvLogicalOption(arg)] {
+			return fmt.Errorf("%w: %q is set by Stream", ErrInvalidStreamConfig, arg)
+		}
+	}
+	return nil
+}
+
+// recvLogicalOption returns option name of argument, e.g. "--slot" for "--slot=foo" and "-S" for "-Sfoo".
+func recvLogicalOption(arg string) string {
+	if strings.HasPrefix(arg, "--") {
+		return strings.SplitN(arg, "=", 2)[0]
+	}
+	if strings.HasPrefix(arg, "-") && len(arg) > 2 {
+		return arg[:2]
+	}
+	return arg
+}
+
+func (c *StreamConfig) binary() string {
+	if c.Binary == "" {
+		return DefaultRecvLogicalBinary
+	}
+	return c.Binary
+}
+
+// args returns pg_recvlogical arguments for given slot, except the connection ones and ExtraArgs.
+func (c *StreamConfig) args(slot string, startPos LogPos) []string {
+	args := []string{"--start", "--file=-", "-S", slot, "-F", strconv.Itoa(int(c.FsyncInterval / time.Second))}
+	if c.CreateSlot {
+		args = append(args, "--create-slot", "-P", outputPlugin)
+		if c.IfNotExists {
+			args = append(args, "--if-not-exists")
+		}
+	}
+	if c.StatusInterval > 0 {
+		args = append(args, "-s", strconv.Itoa(int(c.StatusInterval/time.Second)))
+	}
+	if startPos > 0 {
+		args = append(args, "-I", startPos.String())
+	}
+	if c.EndPos > 0 {
+		args = append(args, "-E", c.EndPos.String())
+	}
+
+	names := make([]string, 0, len(c.PluginOptions))
+	for name := range c.PluginOptions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if value := c.PluginOptions[name]; value != "" {
+			args = append(args, "-o", name+"="+value)
+		} else {
+			args = append(args, "-o", name)
+		}
+	}
+	return args
+}
@@ -0,0 +1,108 @@
+package llsr
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStreamConfigArgs(t *testing.T) {
+	t.Setenv("PGHOST", "")
+	withSocketDirs(t)
+	dbConfig := NewDatabaseConfig("shop")
+	config := NewStreamConfig()
+	config.Binary = "/usr/lib/postgresql/12/bin/pg_recvlogical"
+	config.PluginOptions = map[string]string{"include-xids": "", "format": "binary"}
+	config.StatusInterval = 5 * time.Second
+	config.FsyncInterval = 2 * time.Second
+	config.EndPos = 0x17A4C41EC0
+	config.CreateSlot = true
+	config.IfNotExists = true
+	config.ExtraArgs = []string{"-v"}
+
+	stream, err := NewStreamWithConfig(dbConfig, "my_slot", 0x16B374D848, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stream.cmd.Path != config.Binary {
+		t.Fatalf("Expected binary %s, got %s", config.Binary, stream.cmd.Path)
+	}
+	expected := []string{
+		config.Binary, "--start", "--file=-", "-S", "my_slot", "-F", "2",
+		"--create-slot", "-P", "decoderbufs", "--if-not-exists",
+		"-s", "5", "-I", "16/B374D848", "-E", "17/A4C41EC0",
+		"-o", "format=binary", "-o", "include-xids",
+		"-d", "shop", "-U", "postgres", "-h", "localhost", "-v",
+	}
+	if !reflect.DeepEqual(stream.cmd.Args, expected) {
+		t.Fatalf("Expected arguments %v, got %v", expected, stream.cmd.Args)
+	}
+}
+
+func TestStreamConfigDefaults(t *testing.T) {
+	t.Setenv("PGHOST", "")
+	withSocketDirs(t)
+	stream := NewStream(NewDatabaseConfig("shop"), "my_slot", 0)
+
+	expected := []string{"pg_recvlogical", "--start", "--file=-", "-S", "my_slot", "-F", "0", "-d", "shop", "-U", "postgres", "-h", "localhost"}
+	if !reflect.DeepEqual(stream.cmd.Args, expected) {
+		t.Fatalf("Expected arguments %v, got %v", expected, stream.cmd.Args)
+	}
+}
+
+func TestStreamConfigValidate(t *testing.T) {
+	for _, config := range []*StreamConfig{
+		{PluginOptions: map[string]string{"": "x"}},
+		{PluginOptions: map[string]string{"a=b": "x"}},
+		{StatusInterval: 1500 * time.Millisecond},
+		{FsyncInterval: -time.Second},
+		{IfNotExists: true},
+		{ExtraArgs: []string{"--slot=other"}},
+		{ExtraArgs: []string{"-Sother"}},
+		{ExtraArgs: []string{"-o", "x=y"}},
+		{ExtraArgs: []string{"--endpos", "0/1"}},
+	} {
+		if err := config.Validate(); !errors.Is(err, ErrInvalidStreamConfig) {
+			t.Fatalf("Expected %+v to be rejected, got %v", config, err)
+		}
+		if _, err := NewStreamWithConfig(NewDatabaseConfig("shop"), "my_slot", 0, config); err == nil {
+			t.Fatalf("Expected NewStreamWithConfig to reject %+v", config)
+		}
+	}
+
+	config := &StreamConfig{StatusInterval: time.Second, ExtraArgs: []string{"-v", "--verbose"}}
+	if err := config.Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClientRejectsInvalidStreamConfig(t *testing.T) {
+	config := NewClientConfig(nil, &DummyConverter{}, "my_slot")
+	config.Source = ReplaySource(nil, NewReplayConfig())
+	config.Stream = &StreamConfig{IfNotExists: true}
+
+	if _, err := NewClientWithConfig(config); !errors.Is(err, ErrInvalidStreamConfig) {
+		t.Fatalf("Expected ErrInvalidStreamConfig, got %v", err)
+	}
+}
+
+func TestStreamConfigBuffers(t *testing.T) {
+	config := NewStreamConfig()
+	config.FrameBuffer = 2
+	config.MessageBuffer = 3
+
+	stream, err := NewStreamWithConfig(NewDatabaseConfig("shop"), "my_slot", 0, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cap(stream.dataEvents) != 2 || cap(stream.msgChan) != 3 {
+		t.Fatalf("Expected buffers of 2 frames and 3 messages, got %d and %d", cap(stream.dataEvents), cap(stream.msgChan))
+	}
+
+	config.MessageBuffer = -1
+	if err := config.Validate(); !errors.Is(err, ErrInvalidStreamConfig) {
+		t.Fatalf("Expected negative buffer to be rejected, got %v", err)
+	}
+}
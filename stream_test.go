@@ -1,9 +1,13 @@
 package llsr
 
 import (
+	"bytes"
 	"database/sql"
 	_ "github.com/lib/pq"
 	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
 	"time"
 
@@ -141,3 +145,166 @@ func TestUnexpectedIOBehaviour(t *testing.T) {
 		}
 	})
 }
+
+func TestStreamPassesPasswordInPassfile(t *testing.T) {
+	t.Setenv("PGPASSWORD", "inherited")
+
+	config := NewDatabaseConfig("shop")
+	config.CredentialsProvider = CredentialsProviderFunc(func(*DatabaseConfig) (string, error) {
+		return "rotated", nil
+	})
+	stream := NewStream(config, "llsr_test_slot", 0)
+	if err := stream.setEnv(); err != nil {
+		t.Fatal(err)
+	}
+
+	var passfile string
+	for _, variable := range stream.cmd.Env {
+		if strings.HasPrefix(variable, "PGPASSWORD=") {
+			t.Fatalf("Expected password not to be passed in environment, got %s", variable)
+		}
+		if strings.HasPrefix(variable, "PGPASSFILE=") {
+			passfile = strings.TrimPrefix(variable, "PGPASSFILE=")
+		}
+	}
+	for _, arg := range stream.cmd.Args {
+		if strings.Contains(arg, "rotated") {
+			t.Fatalf("Expected password not to be passed in arguments, got %v", stream.cmd.Args)
+		}
+	}
+
+	if password, err := config.lookupPassfile(passfile); password != "rotated" || err != nil {
+		t.Fatalf("Expected rotated password in passfile, got %q, %v", password, err)
+	}
+
+	stream.removePassfile()
+	if _, err := os.Stat(passfile); !os.IsNotExist(err) {
+		t.Fatalf("Expected passfile to be removed, got %v", err)
+	}
+}
+
+func TestStreamClosesDataAtEndPos(t *testing.T) {
+	dir := t.TempDir()
+	frames := filepath.Join(dir, "frames")
+	if err := os.WriteFile(frames, testRecording(t, 1, 2, 3).Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+	binary := filepath.Join(dir, "pg_recvlogical")
+	if err := os.WriteFile(binary, []byte("#!/bin/sh\ncat "+frames+"\n"), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	config := NewStreamConfig()
+	config.Binary = binary
+	config.EndPos = 3
+	stream, err := NewStreamWithConfig(NewDatabaseConfig("shop"), "llsr_test_slot", 0, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	for expected := uint64(1); ; expected++ {
+		select {
+		case msg, ok := <-stream.Data():
+			if !ok {
+				if expected != 4 {
+					t.Fatalf("Expected Data to be closed after 3 messages, got %d", expected-1)
+				}
+				if err := <-stream.Finished(); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if msg.GetLogPosition() != expected {
+				t.Fatalf("Expected message at %d, got %d", expected, msg.GetLogPosition())
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timeout")
+		}
+	}
+}
+
+func TestClientReconnectsFailingStream(t *testing.T) {
+	dir := t.TempDir()
+	binary := filepath.Join(dir, "pg_recvlogical")
+	if err := os.WriteFile(binary, []byte("#!/bin/sh\necho first >&2\necho second >&2\necho third >&2\nexit 1\n"), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	streamConfig := NewStreamConfig()
+	streamConfig.Binary = binary
+	config := NewClientConfig(nil, &DummyConverter{}, "llsr_test_slot")
+	config.Source = func(startPosition LogPos) (Source, error) {
+		return NewStreamWithConfig(NewDatabaseConfig("shop"), "llsr_test_slot", startPosition, streamConfig)
+	}
+	c, err := NewClientWithConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines, reconnects := 0, 0
+	timeout := time.After(10 * time.Second)
+	for reconnects < 20 {
+		select {
+		case event := <-c.Events():
+			switch event.Type {
+			case EventBackendStdErr:
+				lines++
+			case EventReconnect:
+				reconnects++
+			}
+		case <-timeout:
+			t.Fatal("Timeout")
+		}
+	}
+	c.Close()
+
+	if lines < 3*reconnects {
+		t.Fatalf("Expected 3 stderr lines for each of %d runs, got %d", reconnects, lines)
+	}
+	expectNoGoroutines(t, "llsr.(*Stream)")
+}
+
+// expectNoGoroutines fails when goroutines running functions matching prefix are left after a while.
+func expectNoGoroutines(t *testing.T, prefix string) {
+	var stack []byte
+	for i := 0; i < 50; i++ {
+		stack = make([]byte, 1<<20)
+		stack = stack[:runtime.Stack(stack, true)]
+		if !bytes.Contains(stack, []byte(prefix)) {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("Expected goroutines of %s to exit, got:\n%s", prefix, stack)
+}
+
+func TestClientReconnectsWithoutEventsReader(t *testing.T) {
+	streamConfig := NewStreamConfig()
+	streamConfig.Binary = "false"
+	config := NewClientConfig(nil, &DummyConverter{}, "llsr_test_slot")
+	config.Metrics = &Metrics{}
+	config.Source = func(startPosition LogPos) (Source, error) {
+		return NewStreamWithConfig(NewDatabaseConfig("shop"), "llsr_test_slot", startPosition, streamConfig)
+	}
+	c, err := NewClientWithConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	timeout := time.After(10 * time.Second)
+	for config.Metrics.Snapshot().DroppedEvents < 200 {
+		select {
+		case <-timeout:
+			t.Fatal("Timeout")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	if spawned := runtime.NumGoroutine(); spawned > 50 {
+		t.Fatalf("Expected unread events not to pile up goroutines, got %d goroutines", spawned)
+	}
+}
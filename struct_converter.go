@@ -0,0 +1,271 @@
+package llsr
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/lib/pq/oid"
+	"github.com/liquidm/llsr/decoderbufs"
+)
+
+var (
+	ErrTableNotRegistered = errors.New("llsr: Table is not registered in StructConverter")
+	ErrNotStruct          = errors.New("llsr: StructConverter requires struct or pointer to struct")
+)
+
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+)
+
+// StructChange is produced by StructConverter.
+type StructChange struct {
+	Table  string
+	Op     decoderbufs.Op
+	LogPos LogPos
+	// Before and After are pointers to the struct registered for Table filled from old and new tuple.
+	// They are nil when tuple is empty.
+	Before interface{}
+	After  interface{}
+	// Err is set when message could not be mapped. Before and After may be partially filled then.
+	Err error
+}
+
+// StructConverter fills registered Go structs from RowMessage columns. Struct fields are mapped to
+// columns using `pg:"column_name"` tags; fields without tag or tagged with `pg:"-"` are ignored.
+//
+// NULL values leave non pointer fields untouched and set pointer fields to nil. Values are converted
+// to field type when it is safe (e.g. int32 to int64), timestamps and dates may be mapped to time.Time,
+// and fields implementing sql.Scanner receive extracted value in Scan.
+type StructConverter struct {
+	// Strict makes columns without matching field, fields without matching column in new tuple,
+	// and NULL values of non pointer fields an error.
+	Strict bool
+
+	lock   sync.RWMutex
+	tables map[string]*structMapping
+}
+
+type structMapping struct {
+	typ    reflect.Type
+	fields map[string][]int
+}
+
+// Creates new StructConverter.
+func NewStructConverter(strict bool) *StructConverter {
+	return &StructConverter{
+		Strict: strict,
+		tables: make(map[string]*structMapping),
+	}
+}
+
+// Register maps table to type of sample, which must be a struct or pointer to struct.
+func (s *StructConverter) Register(table string, sample interface{}) error {
+	typ := reflect.TypeOf(sample)
+	if typ != nil && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return ErrNotStruct
+	}
+
+	mapping := &structMapping{typ: typ, fields: make(map[string][]int)}
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		column := field.Tag.Get("pg")
+		if column == "" || column == "-" || field.PkgPath != "" {
+			continue
+		}
+		mapping.fields[column] = field.Index
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.tables == nil {
+		s.tables = make(map[string]*structMapping)
+	}
+	s.tables[table] = mapping
+
+	return nil
+}
+
+// Convert implements Converter interface. It returns *StructChange.
+func (s *StructConverter) Convert(msg *decoderbufs.RowMessage, valuesMap ValuesMap) interface{} {
+	change, err := s.Map(msg, valuesMap)
+	change.Err = err
+	return change
+}
+
+// ConvertChecked implements CheckedConverter interface. It returns *StructChange, or the error instead of setting StructChange.Err.
+func (s *StructConverter) ConvertChecked(msg *decoderbufs.RowMessage, catalog *Catalog) (interface{}, error) {
+	change, err := s.Map(msg, catalog.ValuesMap)
+	if err != nil {
+		return nil, err
+	}
+	return change, nil
+}
+
+// Map fills structs registered for message table.
+func (s *StructConverter) Map(msg *decoderbufs.RowMessage, valuesMap ValuesMap) (*StructChange, error) {
+	change := &StructChange{
+		Table:  msg.GetTable(),
+		Op:     msg.GetOp(),
+		LogPos: LogPos(msg.GetLogPosition()),
+	}
+
+	s.lock.RLock()
+	mapping, ok := s.tables[msg.GetTable()]
+	s.lock.RUnlock()
+	if !ok {
+		return change, ErrTableNotRegistered
+	}
+
+	var err error
+	if change.Before, err = s.fill(mapping, msg.GetOldTuple(), valuesMap, false); err != nil {
+		return change, err
+	}
+	change.After, err = s.fill(mapping, msg.GetNewTuple(), valuesMap, s.Strict)
+	return change, err
+}
+
+func (s *StructConverter) fill(mapping *structMapping, tuple []*decoderbufs.DatumMessage, valuesMap ValuesMap, requireAll bool) (interface{}, error) {
+	if len(tuple) == 0 {
+		return nil, nil
+	}
+
+	target := reflect.New(mapping.typ)
+	present := make(map[string]bool, len(tuple))
+
+	for _, msg := range tuple {
+		column := msg.GetColumnName()
+		present[column] = true
+
+		index, ok := mapping.fields[column]
+		if !ok {
+			if s.Strict {
+				return target.Interface(), fmt.Errorf("llsr: column %s has no matching field in %s", column, mapping.typ)
+			}
+			continue
+		}
+
+		if err := s.setField(target.Elem().FieldByIndex(index), msg, valuesMap); err != nil {
+			return target.Interface(), fmt.Errorf("llsr: column %s: %v", column, err)
+		}
+	}
+
+	if requireAll {
+		for column := range mapping.fields {
+			if !present[column] {
+				return target.Interface(), fmt.Errorf("llsr: field for column %s has no value in %s", column, mapping.typ)
+			}
+		}
+	}
+
+	return target.Interface(), nil
+}
+
+func (s *StructConverter) setField(field reflect.Value, msg *decoderbufs.DatumMessage, valuesMap ValuesMap) error {
+	var value interface{}
+	if msg.ColumnType != nil {
+		extracted, err := valuesMap.Extract(msg)
+		if err != nil && err != ErrUnknownOID {
+			return err
+		}
+		value = extracted
+		if point, ok := value.(*decoderbufs.Point); ok {
+			if point == nil {
+				value = nil
+			}
+		} else if pointer := reflect.ValueOf(value); pointer.Kind() == reflect.Ptr {
+			if pointer.IsNil() {
+				value = nil
+			} else {
+				value = pointer.Elem().Interface()
+			}
+		}
+		if b, ok := value.([]byte); ok && b == nil {
+			value = nil
+		}
+	}
+
+	if field.CanAddr() && field.Addr().Type().Implements(scannerType) {
+		return field.Addr().Interface().(sql.Scanner).Scan(value)
+	}
+
+	if value == nil {
+		if field.Kind() == reflect.Ptr {
+			field.Set(reflect.Zero(field.Type()))
+			return nil
+		}
+		if s.Strict {
+			return fmt.Errorf("NULL value for non pointer field of type %s", field.Type())
+		}
+		return nil
+	}
+
+	if point, ok := value.(*decoderbufs.Point); ok {
+		if field.Type() != reflect.TypeOf(point) {
+			return fmt.Errorf("cannot assign %T to %s", point, field.Type())
+		}
+		field.Set(reflect.ValueOf(point))
+		return nil
+	}
+
+	if field.Kind() == reflect.Ptr {
+		target := reflect.New(field.Type().Elem())
+		if err := assignValue(target.Elem(), value, oid.Oid(msg.GetColumnType())); err != nil {
+			return err
+		}
+		field.Set(target)
+		return nil
+	}
+
+	return assignValue(field, value, oid.Oid(msg.GetColumnType()))
+}
+
+// assignValue sets field to value converting it only between compatible kinds.
+func assignValue(field reflect.Value, value interface{}, columnType oid.Oid) error {
+	if field.Type() == timeType {
+		text, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("cannot assign %T to time.Time", value)
+		}
+		t, err := parseTime(columnType, text)
+		if err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	v := reflect.ValueOf(value)
+	if compatibleKinds(v.Kind(), field.Kind()) || (v.Type() == reflect.TypeOf([]byte(nil)) && (field.Kind() == reflect.String || field.Type() == v.Type())) {
+		if v.Type().ConvertibleTo(field.Type()) {
+			field.Set(v.Convert(field.Type()))
+			return nil
+		}
+	}
+
+	return fmt.Errorf("cannot assign %s to %s", v.Type(), field.Type())
+}
+
+func compatibleKinds(from, to reflect.Kind) bool {
+	switch from {
+	case reflect.Int32:
+		return to == reflect.Int || to == reflect.Int32 || to == reflect.Int64 || to == reflect.Float64
+	case reflect.Int64:
+		return to == reflect.Int || to == reflect.Int64
+	case reflect.Float32, reflect.Float64:
+		return to == reflect.Float32 || to == reflect.Float64
+	case reflect.Bool:
+		return to == reflect.Bool
+	case reflect.String:
+		return to == reflect.String
+	}
+	return false
+}
@@ -0,0 +1,190 @@
+package llsr
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/lib/pq/oid"
+	"github.com/liquidm/llsr/decoderbufs"
+)
+
+type testUserStatus string
+
+type testUser struct {
+	ID       int64              `pg:"id"`
+	Name     string             `pg:"user_name"`
+	Score    float64            `pg:"score"`
+	Note     *string            `pg:"note"`
+	Location *decoderbufs.Point `pg:"location"`
+	Status   testUserStatus     `pg:"status"`
+	Range    []byte             `pg:"range"`
+	Ignored  string             `pg:"-"`
+}
+
+type testUserWithTime struct {
+	ID        int        `pg:"id"`
+	CreatedAt time.Time  `pg:"created_at"`
+	DeletedAt *time.Time `pg:"deleted_at"`
+}
+
+func TestStructConverterRegister(t *testing.T) {
+	converter := NewStructConverter(false)
+
+	if err := converter.Register("users", testUser{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := converter.Register("users", &testUser{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := converter.Register("users", "users"); err != ErrNotStruct {
+		t.Fatalf("Expected Register to return ErrNotStruct. Got: %v", err)
+	}
+}
+
+func TestStructConverterMap(t *testing.T) {
+	converter := NewStructConverter(false)
+	converter.Register("users", testUser{})
+
+	change := converter.Convert(testRowMessage(decoderbufs.Op_UPDATE), ValuesMap{100000: true}).(*StructChange)
+	if change.Err != nil {
+		t.Fatal(change.Err)
+	}
+
+	if change.Table != "users" || change.Op != decoderbufs.Op_UPDATE || change.LogPos != 692097666144 {
+		t.Fatalf("Expected change to describe message. Got: %+v", change)
+	}
+
+	before := change.Before.(*testUser)
+	if before.ID != 1 || before.Name != "" {
+		t.Fatalf("Expected Before to be filled from old tuple. Got: %+v", before)
+	}
+
+	after := change.After.(*testUser)
+	if after.ID != 1 || after.Name != "foo" || after.Score != 1.5 || after.Status != "active" || string(after.Range) != "[1,2)" {
+		t.Fatalf("Expected After to be filled from new tuple. Got: %+v", after)
+	}
+
+	if after.Note != nil {
+		t.Fatalf("Expected NULL to set pointer field to nil. Got: %v", *after.Note)
+	}
+
+	if after.Location.GetX() != 1 || after.Location.GetY() != 2 {
+		t.Fatalf("Expected point to be mapped. Got: %v", after.Location)
+	}
+}
+
+func TestStructConverterNotRegistered(t *testing.T) {
+	converter := NewStructConverter(false)
+
+	change := converter.Convert(testRowMessage(decoderbufs.Op_INSERT), ValuesMap{}).(*StructChange)
+	if change.Err != ErrTableNotRegistered {
+		t.Fatalf("Expected ErrTableNotRegistered. Got: %v", change.Err)
+	}
+}
+
+func TestStructConverterConvertChecked(t *testing.T) {
+	converter := NewStructConverter(false)
+
+	if _, err := converter.ConvertChecked(testRowMessage(decoderbufs.Op_INSERT), &Catalog{ValuesMap: ValuesMap{}}); err != ErrTableNotRegistered {
+		t.Fatalf("Expected ErrTableNotRegistered. Got: %v", err)
+	}
+}
+
+func TestStructConverterStrict(t *testing.T) {
+	type partialUser struct {
+		ID int64 `pg:"id"`
+	}
+
+	lenient := NewStructConverter(false)
+	lenient.Register("users", partialUser{})
+	if _, err := lenient.Map(testRowMessage(decoderbufs.Op_INSERT), ValuesMap{}); err != nil {
+		t.Fatalf("Expected lenient converter to ignore unknown columns. Got: %v", err)
+	}
+
+	strict := NewStructConverter(true)
+	strict.Register("users", partialUser{})
+	if _, err := strict.Map(testRowMessage(decoderbufs.Op_INSERT), ValuesMap{}); err == nil {
+		t.Fatal("Expected strict converter to fail on unknown columns")
+	}
+
+	type extendedUser struct {
+		ID    int64  `pg:"id"`
+		Email string `pg:"email"`
+	}
+	msg := testRowMessage(decoderbufs.Op_INSERT)
+	msg.NewTuple = msg.NewTuple[:1]
+
+	strict.Register("users", extendedUser{})
+	if _, err := strict.Map(msg, ValuesMap{}); err == nil {
+		t.Fatal("Expected strict converter to fail on missing columns")
+	}
+
+	msg.Op = decoderbufs.Op_DELETE.Enum()
+	msg.OldTuple, msg.NewTuple = msg.NewTuple, nil
+	if _, err := strict.Map(msg, ValuesMap{}); err != nil {
+		t.Fatalf("Expected strict converter to accept key only old tuple. Got: %v", err)
+	}
+}
+
+func TestStructConverterIncompatibleTypes(t *testing.T) {
+	type invalidUser struct {
+		ID int32 `pg:"id"`
+	}
+
+	converter := NewStructConverter(false)
+	converter.Register("users", invalidUser{})
+
+	msg := testRowMessage(decoderbufs.Op_INSERT)
+	id := testDatum("id", int64(oid.T_int8))
+	id.DatumInt64 = proto.Int64(1)
+	msg.NewTuple = []*decoderbufs.DatumMessage{id}
+
+	if _, err := converter.Map(msg, ValuesMap{}); err == nil {
+		t.Fatal("Expected int8 column not to be narrowed into int32 field")
+	}
+}
+
+func TestStructConverterTime(t *testing.T) {
+	converter := NewStructConverter(true)
+	converter.Register("users", testUserWithTime{})
+
+	id := testDatum("id", int64(oid.T_int4))
+	id.DatumInt32 = proto.Int32(1)
+	createdAt := testDatum("created_at", int64(oid.T_timestamptz))
+	createdAt.DatumString = proto.String("2020-06-01 14:00:00+02")
+	deletedAt := testDatum("deleted_at", int64(oid.T_timestamp))
+	deletedAt.DatumString = proto.String("2020-06-02 12:00:00")
+
+	msg := &decoderbufs.RowMessage{Table: proto.String("users"), Op: decoderbufs.Op_INSERT.Enum(), NewTuple: []*decoderbufs.DatumMessage{id, createdAt, deletedAt}}
+
+	change, err := converter.Map(msg, ValuesMap{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user := change.After.(*testUserWithTime)
+	if !user.CreatedAt.Equal(time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Fatalf("Expected timestamptz to be parsed. Got: %v", user.CreatedAt)
+	}
+
+	if user.DeletedAt == nil || !user.DeletedAt.Equal(time.Date(2020, 6, 2, 12, 0, 0, 0, time.UTC)) {
+		t.Fatalf("Expected timestamp to be parsed into pointer field. Got: %v", user.DeletedAt)
+	}
+}
+
+func TestClientDeliversStructChangeErr(t *testing.T) {
+	c := testReplayClient(t, NewClientConfig(nil, NewStructConverter(false), "llsr_test_slot"), NewReplayConfig(), 1)
+	defer c.Close()
+
+	select {
+	case update := <-c.Updates():
+		if change, ok := update.(*StructChange); !ok || change.Err != ErrTableNotRegistered {
+			t.Fatalf("Expected StructChange with ErrTableNotRegistered, got %v", update)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timeout")
+	}
+}
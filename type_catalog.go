@@ -0,0 +1,127 @@
+package llsr
+
+import (
+	"database/sql"
+)
+
+// TypeCategory tells what kind of non builtin type TypeInfo describes.
+type TypeCategory int
+
+const (
+	TypeCategoryBase TypeCategory = iota
+	TypeCategoryArray
+	TypeCategoryEnum
+	TypeCategoryDomain
+)
+
+// TypeInfo describes database type which values are sent as text by decoderbufs.
+type TypeInfo struct {
+	OID      int
+	Schema   string
+	Name     string
+	Category TypeCategory
+
+	// BaseType is OID of underlying type for domains, 0 otherwise.
+	BaseType int
+	// ElementType is OID of element type for arrays, 0 otherwise.
+	ElementType int
+	// EnumLabels are enum labels in their sort order. It is nil for non enum types.
+	EnumLabels []string
+}
+
+// EnumIndex returns position of label in EnumLabels or -1 if label is not defined.
+func (t *TypeInfo) EnumIndex(label string) int {
+	for i, l := range t.EnumLabels {
+		if l == label {
+			return i
+		}
+	}
+	return -1
+}
+
+// TypeCatalog maps OIDs of enums, arrays, domains and other text encoded types to their description.
+// Every OID present in TypeCatalog is also set in ValuesMap.
+type TypeCatalog map[int]*TypeInfo
+
+func loadTypeCatalog(db *sql.DB) (TypeCatalog, error) {
+	types := make(TypeCatalog)
+	if err := types.load(db); err != nil {
+		return nil, err
+	}
+	return types, nil
+}
+
+// load reads type definitions from database. Existing entries are replaced, so converters holding
+// the catalog see changes immediately.
+func (t TypeCatalog) load(db *sql.DB) error {
+	rows, err := db.Query(`SELECT t.oid, n.nspname, t.typname, t.typtype, t.typbasetype, t.typelem, t.oid IN (SELECT typarray FROM pg_type)
+		FROM pg_type t JOIN pg_namespace n ON n.oid = t.typnamespace
+		WHERE t.typtype IN ('e', 'd') OR t.oid IN (SELECT typarray FROM pg_type) OR t.typname = 'citext'`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	types := make(TypeCatalog)
+	for rows.Next() {
+		var typType string
+		var isArray bool
+		info := &TypeInfo{}
+
+		if err := rows.Scan(&info.OID, &info.Schema, &info.Name, &typType, &info.BaseType, &info.ElementType, &isArray); err != nil {
+			return err
+		}
+
+		switch {
+		case isArray:
+			info.Category = TypeCategoryArray
+		case typType == "e":
+			info.Category = TypeCategoryEnum
+		case typType == "d":
+			info.Category = TypeCategoryDomain
+		default:
+			info.Category = TypeCategoryBase
+		}
+		if !isArray {
+			info.ElementType = 0
+		}
+
+		types[info.OID] = info
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if err := types.loadEnumLabels(db); err != nil {
+		return err
+	}
+
+	for oid, info := range types {
+		t[oid] = info
+	}
+
+	return nil
+}
+
+func (t TypeCatalog) loadEnumLabels(db *sql.DB) error {
+	rows, err := db.Query("SELECT enumtypid, enumlabel FROM pg_enum ORDER BY enumtypid, enumsortorder")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var oid int
+		var label string
+
+		if err := rows.Scan(&oid, &label); err != nil {
+			return err
+		}
+
+		if info, ok := t[oid]; ok {
+			info.EnumLabels = append(info.EnumLabels, label)
+		}
+	}
+
+	return rows.Err()
+}
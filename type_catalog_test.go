@@ -0,0 +1,74 @@
+package llsr
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+)
+
+func TestTypeCatalogLoadsEnums(t *testing.T) {
+	withValueMapOid(t, func(t *testing.T, db *sql.DB, oid int) {
+		types, err := loadTypeCatalog(db)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		info := types[oid]
+		if info == nil {
+			t.Fatal("Expected TypeCatalog to contain enum type")
+		}
+
+		if info.Name != "llsr_test_enum" || info.Category != TypeCategoryEnum {
+			t.Fatalf("Expected llsr_test_enum enum type. Got: %+v", info)
+		}
+
+		if !reflect.DeepEqual(info.EnumLabels, []string{"foo", "bar", "llsr_foobar"}) {
+			t.Fatalf("Expected enum labels in sort order. Got: %v", info.EnumLabels)
+		}
+	})
+}
+
+func TestTypeCatalogLoadsDomainsAndArrays(t *testing.T) {
+	withTestConnection(t, func(t *testing.T, db *sql.DB) {
+		_, err := db.Exec("CREATE DOMAIN llsr_test_domain AS int4 CHECK (VALUE > 0)")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Exec("DROP DOMAIN llsr_test_domain")
+
+		types, err := loadTypeCatalog(db)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var domain, array *TypeInfo
+		for _, info := range types {
+			switch info.Name {
+			case "llsr_test_domain":
+				domain = info
+			case "_int4":
+				array = info
+			}
+		}
+
+		if domain == nil || domain.Category != TypeCategoryDomain || domain.BaseType != 23 {
+			t.Fatalf("Expected domain over int4. Got: %+v", domain)
+		}
+
+		if array == nil || array.Category != TypeCategoryArray || array.ElementType != 23 {
+			t.Fatalf("Expected int4 array. Got: %+v", array)
+		}
+	})
+}
+
+func TestTypeInfoEnumIndex(t *testing.T) {
+	info := &TypeInfo{Category: TypeCategoryEnum, EnumLabels: []string{"foo", "bar"}}
+
+	if info.EnumIndex("bar") != 1 {
+		t.Fatal("Expected EnumIndex to return label position")
+	}
+
+	if info.EnumIndex("baz") != -1 {
+		t.Fatal("Expected EnumIndex to return -1 for unknown label")
+	}
+}
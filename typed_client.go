@@ -0,0 +1,127 @@
+package llsr
+
+import (
+	"sync"
+
+	"github.com/liquidm/llsr/decoderbufs"
+)
+
+// TypedConverter is a type safe counterpart of Converter.
+type TypedConverter[T any] interface {
+	// Converts RowMessage into app specific data.
+	Convert(*decoderbufs.RowMessage, ValuesMap) T
+}
+
+// TypedConverterFunc adapts function to TypedConverter interface.
+type TypedConverterFunc[T any] func(*decoderbufs.RowMessage, ValuesMap) T
+
+// Convert implements TypedConverter interface.
+func (f TypedConverterFunc[T]) Convert(msg *decoderbufs.RowMessage, valuesMap ValuesMap) T {
+	return f(msg, valuesMap)
+}
+
+// Change is a value produced by TypedConverter together with metadata of converted message.
+type Change[T any] struct {
+	Table  string
+	Op     decoderbufs.Op
+	LogPos LogPos
+	Value  T
+}
+
+// TypedClient is a type safe counterpart of Client. It is implemented on top of Client, so both
+// behave the same way apart from Updates type.
+type TypedClient[T any] interface {
+	// Updates are database events converted by TypedConverter. The channel is closed when stream ends.
+	Updates() <-chan Change[T]
+	// Events are internal messages received during communication with LLSR.
+	Events() <-chan *Event
+	// Resnapshot starts incremental snapshot of given table, which rows are emitted on Updates interleaved with live changes.
+	Resnapshot(table string) error
+	// Close makes sure every resources are released succesfully
+	Close()
+}
+
+type typedConverter[T any] struct {
+	converter TypedConverter[T]
+}
+
+func (t *typedConverter[T]) Convert(msg *decoderbufs.RowMessage, valuesMap ValuesMap) interface{} {
+	return Change[T]{
+		Table:  msg.GetTable(),
+		Op:     msg.GetOp(),
+		LogPos: LogPos(msg.GetLogPosition()),
+		Value:  t.converter.Convert(msg, valuesMap),
+	}
+}
+
+type typedClient[T any] struct {
+	Client
+
+	updates   chan Change[T]
+	closeOnce sync.Once
+	closeChan chan struct{}
+	done      chan struct{}
+}
+
+// Creates new TypedClient using given ClientConfig. config.Converter and config.CheckedConverter are ignored;
+// converter is used instead.
+func NewTypedClient[T any](config *ClientConfig, converter TypedConverter[T]) (TypedClient[T], error) {
+	untypedConfig := *config
+	untypedConfig.Converter = &typedConverter[T]{converter: converter}
+	untypedConfig.CheckedConverter = nil
+
+	client, err := NewClientWithConfig(&untypedConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return newTypedClient[T](client), nil
+}
+
+func newTypedClient[T any](client Client) *typedClient[T] {
+	typed := &typedClient[T]{
+		Client:    client,
+		updates:   make(chan Change[T]),
+		closeChan: make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+
+	go typed.recvUpdates()
+
+	return typed
+}
+
+// Updates produces Change objects with values converted by TypedConverter.
+func (t *typedClient[T]) Updates() <-chan Change[T] {
+	return t.updates
+}
+
+// Close stops forwarding updates, closes Updates and underlying Client. It may be called more than once.
+func (t *typedClient[T]) Close() {
+	t.closeOnce.Do(func() {
+		close(t.closeChan)
+	})
+	<-t.done
+	t.Client.Close()
+}
+
+func (t *typedClient[T]) recvUpdates() {
+	defer close(t.done)
+	defer close(t.updates)
+
+	for {
+		select {
+		case update, ok := <-t.Client.Updates():
+			if !ok {
+				return
+			}
+			select {
+			case t.updates <- update.(Change[T]):
+			case <-t.closeChan:
+				return
+			}
+		case <-t.closeChan:
+			return
+		}
+	}
+}
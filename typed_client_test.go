@@ -0,0 +1,121 @@
+package llsr
+
+import (
+	"testing"
+	"time"
+
+	"github.com/liquidm/llsr/decoderbufs"
+)
+
+type testUntypedClient struct {
+	updates chan interface{}
+	events  chan *Event
+	closed  bool
+}
+
+func (c *testUntypedClient) Updates() <-chan interface{}   { return c.updates }
+func (c *testUntypedClient) Events() <-chan *Event         { return c.events }
+func (c *testUntypedClient) Resnapshot(table string) error { return nil }
+func (c *testUntypedClient) Close()                        { c.closed = true }
+
+func TestTypedConverter(t *testing.T) {
+	converter := &typedConverter[string]{converter: TypedConverterFunc[string](func(msg *decoderbufs.RowMessage, valuesMap ValuesMap) string {
+		return msg.GetTable()
+	})}
+
+	msg := testRowMessage(decoderbufs.Op_DELETE)
+	change, ok := converter.Convert(msg, ValuesMap{}).(Change[string])
+	if !ok {
+		t.Fatal("Expected typedConverter to produce Change")
+	}
+
+	if change.Value != "users" || change.Table != "users" || change.Op != decoderbufs.Op_DELETE || change.LogPos != 692097666144 {
+		t.Fatalf("Expected Change to describe message. Got: %+v", change)
+	}
+}
+
+func TestTypedClientForwardsUpdates(t *testing.T) {
+	untyped := &testUntypedClient{updates: make(chan interface{}), events: make(chan *Event)}
+	client := newTypedClient[int](untyped)
+
+	go func() {
+		untyped.updates <- Change[int]{Table: "users", Value: 42}
+	}()
+
+	select {
+	case change := <-client.Updates():
+		if change.Value != 42 {
+			t.Fatalf("Expected to receive forwarded value. Got: %v", change.Value)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timeout")
+	}
+
+	if client.Events() != untyped.Events() {
+		t.Fatal("Expected TypedClient to expose Client events")
+	}
+
+	client.Close()
+
+	if !untyped.closed {
+		t.Fatal("Expected Close to close underlying Client")
+	}
+}
+
+func TestTypedClientClosesUpdates(t *testing.T) {
+	untyped := &testUntypedClient{updates: make(chan interface{}), events: make(chan *Event)}
+	client := newTypedClient[int](untyped)
+
+	close(untyped.updates)
+
+	select {
+	case change, ok := <-client.Updates():
+		if ok {
+			t.Fatalf("Expected Updates to be closed. Got: %v", change)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timeout")
+	}
+
+	client.Close()
+}
+
+func TestTypedClientCloseClosesUpdates(t *testing.T) {
+	untyped := &testUntypedClient{updates: make(chan interface{}), events: make(chan *Event)}
+	client := newTypedClient[int](untyped)
+
+	client.Close()
+	client.Close()
+
+	select {
+	case change, ok := <-client.Updates():
+		if ok {
+			t.Fatalf("Expected Updates to be closed. Got: %v", change)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timeout")
+	}
+}
+
+func TestTypedClientIgnoresCheckedConverter(t *testing.T) {
+	config := NewClientConfig(nil, nil, "llsr_test_slot")
+	config.CheckedConverter = rejectingConverter{}
+	config.Source = ReplaySource(testRecording(t, 1), NewReplayConfig())
+
+	client, err := NewTypedClient[string](config, TypedConverterFunc[string](func(msg *decoderbufs.RowMessage, valuesMap ValuesMap) string {
+		return msg.GetTable()
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	select {
+	case change := <-client.Updates():
+		if change.Value != "users" {
+			t.Fatalf("Expected change of users, got %v", change)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timeout")
+	}
+}
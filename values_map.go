@@ -1,8 +1,8 @@
 package llsr
 
 import (
-	"database/sql"
 	"errors"
+	"sort"
 
 	_ "github.com/lib/pq"
 	"github.com/lib/pq/oid"
@@ -14,17 +14,9 @@ var (
 )
 
 // ValuesMap is used in Converter interface.
-// It has true set to every oid which is value type in database.
+// It has true set to every oid which is value type in database. See TypeCatalog for details about these types.
 type ValuesMap map[int]bool
 
-func loadValuesMap(dbConfig *DatabaseConfig) (ValuesMap, error) {
-	valuesMap := make(ValuesMap)
-	if err := valuesMap.load(dbConfig); err != nil {
-		return nil, err
-	}
-	return valuesMap, nil
-}
-
 // Extract value from DatumMessage. Returned value is always a pointer.
 // Returns ErrUnknownOID if value is of unonkown OID. If returned with error, value is []byte or nil.
 func (v ValuesMap) Extract(m *decoderbufs.DatumMessage) (interface{}, error) {
@@ -62,36 +54,23 @@ func (v ValuesMap) Extract(m *decoderbufs.DatumMessage) (interface{}, error) {
 	return value, err
 }
 
-func (v ValuesMap) load(dbConfig *DatabaseConfig) error {
-	db, err := sql.Open("postgres", dbConfig.ToConnectionString())
-	if err != nil {
-		return err
-	}
-	defer db.Close()
-
-	oidQueries := []string{
-		"SELECT enumtypid FROM pg_enum;",
-		"SELECT DISTINCT typarray FROM pg_type WHERE typarray > 0;",
-		"SELECT oid FROM pg_type WHERE typname = 'citext'",
-	}
-	for _, query := range oidQueries {
-		rows, err := db.Query(query)
-		if err != nil {
-			return err
-		}
-
-		for rows.Next() {
-			var oid int
-
-			if err := rows.Scan(&oid); err != nil {
-				return err
-			}
+// Known reports whether values of given OID can be extracted without ErrUnknownOID.
+func (v ValuesMap) Known(columnType int64) bool {
+	_, err := v.Extract(&decoderbufs.DatumMessage{ColumnType: &columnType})
+	return err != ErrUnknownOID
+}
 
+// merge sets every OID described in types and returns sorted list of OIDs which were not set before.
+// The map is updated in place, so converters holding it see new OIDs immediately.
+func (v ValuesMap) merge(types TypeCatalog) []int {
+	var discovered []int
+	for oid := range types {
+		if !v[oid] {
 			v[oid] = true
+			discovered = append(discovered, oid)
 		}
-
-		rows.Close()
 	}
+	sort.Ints(discovered)
 
-	return nil
+	return discovered
 }
@@ -3,12 +3,15 @@ package llsr
 import (
 	"database/sql"
 	"testing"
+	"time"
 
+	"github.com/golang/protobuf/proto"
 	_ "github.com/lib/pq"
+	"github.com/lib/pq/oid"
 	"github.com/liquidm/llsr/decoderbufs"
 )
 
-type testValueMapOidCallback func(*testing.T, int)
+type testValueMapOidCallback func(*testing.T, *sql.DB, int)
 
 func withValueMapOid(t *testing.T, cb testValueMapOidCallback) {
 	db, err := sql.Open("postgres", "sslmode=disable user="+dbUser()+" dbname="+dbName())
@@ -29,18 +32,16 @@ func withValueMapOid(t *testing.T, cb testValueMapOidCallback) {
 		t.Fatal(err)
 	}
 
-	cb(t, oid)
+	cb(t, db, oid)
 }
 
 func TestValueMapDiscovery(t *testing.T) {
-	withValueMapOid(t, func(t *testing.T, oid int) {
-		dbConfig := NewDatabaseConfig(dbName())
-		dbConfig.User = dbUser()
-
-		valuesMap, err := loadValuesMap(dbConfig)
+	withValueMapOid(t, func(t *testing.T, db *sql.DB, oid int) {
+		catalog, err := loadCatalog(db)
 		if err != nil {
 			t.Fatal(err)
 		}
+		valuesMap := catalog.ValuesMap
 
 		if !valuesMap[oid] {
 			t.Fatal("Expected ValueMap.load() to discover enum types")
@@ -53,14 +54,12 @@ func TestValueMapDiscovery(t *testing.T) {
 }
 
 func TestExtractValue(t *testing.T) {
-	withValueMapOid(t, func(t *testing.T, oid int) {
-		dbConfig := NewDatabaseConfig(dbName())
-		dbConfig.User = dbUser()
-
-		valuesMap, err := loadValuesMap(dbConfig)
+	withValueMapOid(t, func(t *testing.T, db *sql.DB, oid int) {
+		catalog, err := loadCatalog(db)
 		if err != nil {
 			t.Fatal(err)
 		}
+		valuesMap := catalog.ValuesMap
 
 		oid64 := int64(oid)
 
@@ -88,3 +87,66 @@ func TestExtractValue(t *testing.T) {
 		}
 	})
 }
+
+func TestValuesMapMerge(t *testing.T) {
+	valuesMap := ValuesMap{100000: true}
+
+	discovered := valuesMap.merge(TypeCatalog{100002: &TypeInfo{}, 100000: &TypeInfo{}, 100001: &TypeInfo{}})
+
+	if !valuesMap[100001] || !valuesMap[100002] {
+		t.Fatal("Expected ValuesMap.merge() to set every oid from TypeCatalog")
+	}
+
+	if len(discovered) != 2 || discovered[0] != 100001 || discovered[1] != 100002 {
+		t.Fatalf("Expected ValuesMap.merge() to return sorted new oids. Got: %v", discovered)
+	}
+}
+
+func TestValuesMapKnown(t *testing.T) {
+	valuesMap := ValuesMap{100000: true}
+
+	if !valuesMap.Known(int64(oid.T_int4)) {
+		t.Fatal("Expected builtin oid to be known")
+	}
+
+	if !valuesMap.Known(100000) {
+		t.Fatal("Expected oid present in ValuesMap to be known")
+	}
+
+	if valuesMap.Known(100001) {
+		t.Fatal("Expected oid missing from ValuesMap to be unknown")
+	}
+}
+
+func TestClientReloadsUnknownTypesOnce(t *testing.T) {
+	withValueMapOid(t, func(t *testing.T, db *sql.DB, enumOid int) {
+		config := NewClientConfig(nil, &DummyConverter{}, "llsr_test_slot")
+		config.ValuesMapReloadInterval = time.Nanosecond
+		c := &client{
+			config:  config,
+			db:      db,
+			catalog: &Catalog{ValuesMap: make(ValuesMap), Types: make(TypeCatalog), Relations: newRelationCache(db)},
+			events:  make(chan *Event, 10),
+		}
+
+		unresolvable := int64(2000000000)
+		msg := &decoderbufs.RowMessage{
+			Table:    proto.String("llsr_test_table"),
+			Op:       decoderbufs.Op_INSERT.Enum(),
+			NewTuple: []*decoderbufs.DatumMessage{testDatum("mood", int64(enumOid)), testDatum("other", unresolvable)},
+		}
+
+		if unknown := c.unknownTypes(msg); len(unknown) != 2 {
+			t.Fatalf("Expected both types to be unknown. Got: %v", unknown)
+		}
+
+		c.reloadUnknownTypes(msg)
+
+		if !c.catalog.ValuesMap.Known(int64(enumOid)) {
+			t.Fatal("Expected reload to resolve enum type")
+		}
+		if unknown := c.unknownTypes(msg); len(unknown) != 0 {
+			t.Fatalf("Expected type unresolved by reload not to trigger reloads again. Got: %v", unknown)
+		}
+	})
+}